@@ -0,0 +1,89 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/pkg/sendico"
+)
+
+// TermItems is the new items found for a single term.
+type TermItems struct {
+	TermEN string
+	Items  []sendico.Item
+	Notes  map[string]db.ItemNote
+}
+
+// matchGroup is a set of items that matched exactly the same terms.
+type matchGroup struct {
+	Terms []string
+	Items []sendico.Item
+	// Notes are the notes of the items from any of the terms they matched.
+	Notes map[string]db.ItemNote
+}
+
+// groupMatches dedupes items by shop and code across terms, then groups them by the terms they matched. Groups and
+// items keep the order they were first seen in.
+func groupMatches(batch []TermItems) []matchGroup {
+	var (
+		keys  []string
+		terms = make(map[string][]string)
+		items = make(map[string]sendico.Item)
+		notes = make(map[string]db.ItemNote)
+	)
+
+	for _, termItems := range batch {
+		for _, item := range termItems.Items {
+			key := db.NoteKey(item)
+			if _, ok := items[key]; !ok {
+				keys = append(keys, key)
+				items[key] = item
+			}
+			terms[key] = appendUnique(terms[key], termItems.TermEN)
+			if note, ok := termItems.Notes[key]; ok {
+				notes[key] = note
+			}
+		}
+	}
+
+	var groups []matchGroup
+	index := make(map[string]int)
+	for _, key := range keys {
+		groupKey := strings.Join(terms[key], "\x00")
+		i, ok := index[groupKey]
+		if !ok {
+			i = len(groups)
+			index[groupKey] = i
+			groups = append(groups, matchGroup{Terms: terms[key], Notes: make(map[string]db.ItemNote)})
+		}
+		groups[i].Items = append(groups[i].Items, items[key])
+		if note, ok := notes[key]; ok {
+			groups[i].Notes[key] = note
+		}
+	}
+
+	return groups
+}
+
+// quoteTerms formats terms as a quoted list, e.g. `"a", "b" and "c"`.
+func quoteTerms(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = fmt.Sprintf("%q", term)
+	}
+
+	if len(quoted) == 1 {
+		return quoted[0]
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " and " + quoted[len(quoted)-1]
+}
+
+func appendUnique(s []string, v string) []string {
+	for _, existing := range s {
+		if existing == v {
+			return s
+		}
+	}
+	return append(s, v)
+}
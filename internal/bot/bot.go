@@ -4,17 +4,18 @@ import (
 	"fmt"
 	"log/slog"
 	"runtime/debug"
+	"slices"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/robherley/sendibot/internal/bot/cmd"
 	"github.com/robherley/sendibot/internal/bot/emoji"
 	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/notify"
 	"github.com/robherley/sendibot/pkg/sendico"
 )
 
-// MaxMessagesPerNotify is the maximum number of messages to send in a single notify.
-// This number was based on the discord maximum of 10 embeds per message.
-const MaxMessagesPerNotify = 10
+// MaxEmbedsPerMessage is the discord maximum of embeds in a single message, items past it are sent in more messages.
+const MaxEmbedsPerMessage = 10
 
 type Bot struct {
 	DB      db.DB
@@ -23,9 +24,45 @@ type Bot struct {
 	session  *discordgo.Session
 	emojis   *emoji.Store
 	handlers map[string]cmd.Handler
+	webhooks *cmd.Webhooks
+	emails   *cmd.Emails
+	pushes   *cmd.Pushes
+	feeds    *cmd.Feeds
 }
 
-func New(token string, db db.DB, sendico *sendico.Client) (*Bot, error) {
+type Option func(*Bot)
+
+// WithWebhooks lets subscriptions post to webhooks, hasDefault is set if there is a globally configured URL.
+func WithWebhooks(hasDefault bool) Option {
+	return func(b *Bot) {
+		b.webhooks = &cmd.Webhooks{HasDefault: hasDefault}
+	}
+}
+
+// WithEmails lets users verify an email address and subscriptions email new items to it, sender sends the confirm
+// codes.
+func WithEmails(sender cmd.ConfirmCodeSender) Option {
+	return func(b *Bot) {
+		b.emails = &cmd.Emails{Sender: sender}
+	}
+}
+
+// WithPushes lets users set an ntfy or Gotify server and subscriptions push new items to it, tester pushes the test
+// notifications.
+func WithPushes(tester cmd.PushTester) Option {
+	return func(b *Bot) {
+		b.pushes = &cmd.Pushes{Tester: tester}
+	}
+}
+
+// WithFeeds lets users get the Atom feed of their subscriptions, url returns the URL of the feed with a token.
+func WithFeeds(url func(token string) string) Option {
+	return func(b *Bot) {
+		b.feeds = &cmd.Feeds{URL: url}
+	}
+}
+
+func New(token string, db db.DB, sendico *sendico.Client, opts ...Option) (*Bot, error) {
 	session, err := discordgo.New("Bot " + token)
 	if err != nil {
 		return nil, err
@@ -39,13 +76,34 @@ func New(token string, db db.DB, sendico *sendico.Client) (*Bot, error) {
 		session: session,
 	}
 
+	for _, opt := range opts {
+		opt(b)
+	}
+
 	b.emojis = emoji.NewStore()
-	b.handlers = buildHandlers(
+	handlers := []cmd.Handler{
 		cmd.NewPing(),
-		cmd.NewSubscribe(db, sendico, b.emojis),
+		cmd.NewSubscribe(db, sendico, b.emojis, b.webhooks, b.emails, b.pushes),
 		cmd.NewSubscriptions(db, b.emojis),
 		cmd.NewUnsubscribe(db),
-	)
+		cmd.NewPause(db),
+		cmd.NewResume(db),
+		cmd.NewDelivery(db),
+		cmd.NewChannelRoles(db),
+		cmd.NewHistory(db, b.emojis),
+		cmd.NewPriceCheck(db, sendico, b.emojis),
+		cmd.NewSeller(db, sendico, b.emojis),
+	}
+	if b.emails != nil {
+		handlers = append(handlers, cmd.NewEmail(db, b.emails))
+	}
+	if b.pushes != nil {
+		handlers = append(handlers, cmd.NewNotify(db, b.pushes))
+	}
+	if b.feeds != nil {
+		handlers = append(handlers, cmd.NewFeed(db, b.feeds))
+	}
+	b.handlers = buildHandlers(handlers...)
 
 	return b, nil
 }
@@ -84,6 +142,18 @@ func (b *Bot) Start() (err error) {
 			if err := handler.Handle(s, i); err != nil {
 				log.Error("failed", "err", err)
 			}
+
+			b.resumePaused(s, i)
+		case discordgo.InteractionApplicationCommandAutocomplete:
+			handler, ok := b.handlers[i.ApplicationCommandData().Name]
+			if !ok {
+				log.Warn("no handler found")
+				return
+			}
+
+			if err := handler.Handle(s, i); err != nil {
+				log.Error("failed", "err", err)
+			}
 		case discordgo.InteractionMessageComponent:
 			customID := i.MessageComponentData().CustomID
 			log = log.With("custom_id", customID)
@@ -99,6 +169,8 @@ func (b *Bot) Start() (err error) {
 			if err := handler.Handle(s, i); err != nil {
 				log.Error("failed", "err", err)
 			}
+
+			b.resumePaused(s, i)
 		default:
 			log.Warn("unknown interaction type")
 		}
@@ -107,82 +179,150 @@ func (b *Bot) Start() (err error) {
 	return nil
 }
 
-func (b *Bot) Close() error {
-	return b.session.Close()
-}
+// resumePaused resumes subscriptions that were paused because the user couldn't be messaged, now that they are talking
+// to the bot again. It is called after the command responded, so the notice can be a follow up.
+func (b *Bot) resumePaused(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	log := LogWith(i)
 
-func (b *Bot) NotifyNewItems(termEN, userID string, items []sendico.Item) error {
-	dm, err := b.session.UserChannelCreate(userID)
+	userID := cmd.UserID(i)
+	if userID == "" {
+		return
+	}
+
+	resumed, err := b.DB.ResumeUserSubscriptions(userID, db.PauseReasonUnreachable)
 	if err != nil {
-		return err
+		log.Error("failed to resume subscriptions", "err", err)
+		return
 	}
 
-	total := len(items)
-	truncated := false
-	if len(items) > MaxMessagesPerNotify {
-		items = items[:MaxMessagesPerNotify]
-		truncated = true
+	if resumed == 0 {
+		return
 	}
 
-	embeds := make([]*discordgo.MessageEmbed, 0, len(items))
-	for _, item := range items {
-		// TODOs:
-		// - auction specific fields
-		// - translate???
+	log.Info("resumed subscriptions", "count", resumed)
+	_, err = s.FollowupMessageCreate(i.Interaction, false, &discordgo.WebhookParams{
+		Content: fmt.Sprintf("▶️ Welcome back! I resumed %d subscription(s) that were paused because I couldn't DM you. Make sure your DMs are open so you don't miss anything.", resumed),
+		Flags:   discordgo.MessageFlagsEphemeral,
+	})
+	if err != nil {
+		log.Error("failed to send resume notice", "err", err)
+	}
+}
+
+func (b *Bot) Close() error {
+	return b.session.Close()
+}
 
-		shop := item.Shop.Name()
-		if b.emojis.Has(item.Shop.Identifier()) {
-			shop = b.emojis.For(item.Shop.Identifier()) + " " + shop
+func (b *Bot) newItemsMessages(batch []TermItems) []message {
+	terms := make([]string, 0, len(batch))
+	embeds := make([]*discordgo.MessageEmbed, 0)
+	items := make([]sendico.Item, 0)
+	for _, group := range groupMatches(batch) {
+		for _, term := range group.Terms {
+			terms = appendUnique(terms, term)
 		}
 
-		embed := &discordgo.MessageEmbed{
-			Title: item.Name,
-			Image: &discordgo.MessageEmbedImage{
-				URL: item.Image,
-			},
-			Fields: []*discordgo.MessageEmbedField{
-				{
-					Name:   "Price",
-					Value:  fmt.Sprintf("¥%d ($%d)", item.PriceYen, item.PriceUSD),
-					Inline: true,
-				},
-				{
-					Name:   "Shop",
-					Value:  shop,
-					Inline: true,
-				},
-			},
-			URL: item.SendicoLink(),
+		for _, item := range group.Items {
+			embed := cmd.ItemEmbed(b.emojis, item)
+			note := group.Notes[db.NoteKey(item)]
+			if note.Deal != nil {
+				embed.Fields = append(embed.Fields, cmd.DealEmbedField(*note.Deal))
+			}
+			if len(note.Variants) > 0 {
+				embed.Fields = append(embed.Fields, cmd.VariantsEmbedField(b.emojis, note.Variants))
+			}
+			if note.Followed {
+				embed.Fields = append(embed.Fields, cmd.FollowedEmbedField(item))
+			}
+			if note.Irrelevance > 0 {
+				embed.Fields = append(embed.Fields, cmd.RelevanceEmbedField(note.Irrelevance))
+			}
+			// numbered for the vote buttons
+			embed.Footer = &discordgo.MessageEmbedFooter{
+				Text: fmt.Sprintf("#%d · Matched %s", len(items)+1, quoteTerms(group.Terms)),
+			}
+			embeds = append(embeds, embed)
+			items = append(items, item)
 		}
+	}
 
-		if item.Category != nil {
-			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
-				Name:   "Category",
-				Value:  item.Category.String(),
-				Inline: true,
-			})
+	packed := packEmbeds(embeds)
+	messages := make([]message, 0, len(packed))
+	offset := 0
+	for i, embeds := range packed {
+		content := fmt.Sprintf("🔔 New items for %s!", quoteTerms(terms))
+		if len(packed) > 1 {
+			content += fmt.Sprintf(" (%d/%d)", i+1, len(packed))
 		}
 
-		embeds = append(embeds, embed)
+		shown := items[offset : offset+len(embeds)]
+		messages = append(messages, message{
+			MessageSend: &discordgo.MessageSend{
+				Content:    content,
+				Embeds:     embeds,
+				Components: cmd.FeedbackComponents(offset+1, shown),
+			},
+			items: shown,
+		})
+		offset += len(embeds)
 	}
 
-	msg, err := b.session.ChannelMessageSendComplex(dm.ID, &discordgo.MessageSend{
-		Content: fmt.Sprintf("🔔 New items for %q!", termEN),
-		Embeds:  embeds,
-	})
+	return messages
+}
+
+// message is a message of a notification, along with the items it shows so a notification that is only partly sent
+// can tell which events went out.
+type message struct {
+	*discordgo.MessageSend
+	items []sendico.Item
+}
+
+// sentEvents returns the indexes of the events whose items were all shown in the sent messages.
+func sentEvents(events []notify.Event, sent []message) []int {
+	shown := make(map[string]bool)
+	for _, msg := range sent {
+		for _, item := range msg.items {
+			shown[db.NoteKey(item)] = true
+		}
+	}
+
+	var indexes []int
+	for i, event := range events {
+		if !slices.ContainsFunc(event.Items, func(item sendico.Item) bool { return !shown[db.NoteKey(item)] }) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// sendDM sends messages to the user's DMs, in order. See sendMessages for what it returns.
+func (b *Bot) sendDM(userID string, messages []message) (int, error) {
+	dm, err := b.session.UserChannelCreate(userID)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	if truncated {
-		content := fmt.Sprintf("⚠️ BTW! I only sent %d out of %d items. This means there were a lot results from when I last checked. Try refining your search terms a bit more or listen to less shops!", MaxMessagesPerNotify, total)
-		_, err = b.session.ChannelMessageSendReply(dm.ID, content, msg.Reference())
-		if err != nil {
-			return err
+	return b.sendChannel(dm.ID, messages)
+}
+
+// sendChannel sends messages to a channel, in order. See sendMessages for what it returns.
+func (b *Bot) sendChannel(channelID string, messages []message) (int, error) {
+	return sendMessages(messages, func(msg *discordgo.MessageSend) error {
+		_, err := b.session.ChannelMessageSendComplex(channelID, msg)
+		return err
+	})
+}
+
+// sendMessages sends the messages of a notification in order, stopping at the first that fails. It returns how many
+// were sent, so the events that already went out aren't sent again.
+func sendMessages(messages []message, send func(*discordgo.MessageSend) error) (int, error) {
+	for i, msg := range messages {
+		if err := send(msg.MessageSend); err != nil {
+			return i, err
 		}
 	}
 
-	return nil
+	return len(messages), nil
 }
 
 func (b *Bot) Unregister(guild string) error {
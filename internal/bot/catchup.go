@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robherley/sendibot/pkg/sendico"
+)
+
+// CatchUpTopItems is the number of items listed per term in a catch up summary.
+const CatchUpTopItems = 5
+
+func (b *Bot) catchUpMessages(batch []TermItems) []message {
+	total := 0
+	embeds := make([]*discordgo.MessageEmbed, 0, len(batch))
+	// an embed sums up all of the term's items, not only the ones it lists
+	shown := make([][]sendico.Item, 0, len(batch))
+	for _, termItems := range batch {
+		total += len(termItems.Items)
+
+		top := slices.Clone(termItems.Items)
+		slices.SortStableFunc(top, func(a, b sendico.Item) int {
+			return a.PriceYen - b.PriceYen
+		})
+		top = top[:min(len(top), CatchUpTopItems)]
+
+		description := strings.Builder{}
+		for _, item := range top {
+			description.WriteString(b.digestLine(item, termItems.Notes))
+		}
+		if len(termItems.Items) > len(top) {
+			description.WriteString(fmt.Sprintf("…and %d more", len(termItems.Items)-len(top)))
+		}
+
+		embeds = append(embeds, &discordgo.MessageEmbed{
+			Title:       fmt.Sprintf("🔔 %q: %d new item(s)", termItems.TermEN, len(termItems.Items)),
+			Description: description.String(),
+		})
+		shown = append(shown, termItems.Items)
+	}
+
+	packed := packEmbeds(embeds)
+	messages := make([]message, 0, len(packed))
+	for i, items := range packedItems(packed, shown) {
+		msg := &discordgo.MessageSend{Embeds: packed[i]}
+		if i == 0 {
+			msg.Content = fmt.Sprintf("👋 While I was away, %d new item(s) were listed. Here are the cheapest:", total)
+		}
+		messages = append(messages, message{MessageSend: msg, items: items})
+	}
+
+	return messages
+}
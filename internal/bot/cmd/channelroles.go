@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robherley/sendibot/internal/db"
+)
+
+func NewChannelRoles(db db.DB) Handler {
+	return &ChannelRoles{db}
+}
+
+type ChannelRoles struct {
+	db db.DB
+}
+
+func (cmd *ChannelRoles) Name() string {
+	return "channelroles"
+}
+
+func (cmd *ChannelRoles) Description() string {
+	return "Choose which roles may create subscriptions that post in this server's channels."
+}
+
+func (cmd *ChannelRoles) DefaultMemberPermissions() int64 {
+	return discordgo.PermissionManageServer
+}
+
+func (cmd *ChannelRoles) Options() []*discordgo.ApplicationCommandOption {
+	role := []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionRole,
+			Name:        "role",
+			Description: "The role",
+			Required:    true,
+		},
+	}
+
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "allow",
+			Description: "Let members with a role create channel subscriptions",
+			Options:     role,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "disallow",
+			Description: "Stop members with a role from creating channel subscriptions",
+			Options:     role,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "list",
+			Description: "Show the roles that may create channel subscriptions",
+		},
+	}
+}
+
+func (cmd *ChannelRoles) Handle(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return nil
+	}
+
+	respond := func(content string) error {
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+				Flags:   discordgo.MessageFlagsEphemeral,
+				AllowedMentions: &discordgo.MessageAllowedMentions{
+					Parse: []discordgo.AllowedMentionType{},
+				},
+			},
+		})
+	}
+
+	if i.GuildID == "" || i.Member == nil {
+		return respond("⛔ Channel roles can only be set in a server.")
+	}
+
+	if i.Member.Permissions&discordgo.PermissionManageServer == 0 {
+		return respond("⛔ You need the Manage Server permission to change channel roles.")
+	}
+
+	guild, err := cmd.db.GetGuild(i.GuildID)
+	if err != nil {
+		return err
+	}
+
+	data := i.ApplicationCommandData()
+	if len(data.Options) == 0 {
+		return nil
+	}
+
+	subcommand := data.Options[0]
+	switch subcommand.Name {
+	case "allow", "disallow":
+		if len(subcommand.Options) == 0 {
+			return nil
+		}
+
+		roleID := subcommand.Options[0].RoleValue(nil, i.GuildID).ID
+		if subcommand.Name == "allow" {
+			if !slices.Contains(guild.ChannelRoles, roleID) {
+				guild.ChannelRoles = append(guild.ChannelRoles, roleID)
+			}
+		} else {
+			guild.ChannelRoles = slices.DeleteFunc(guild.ChannelRoles, func(id string) bool {
+				return id == roleID
+			})
+		}
+
+		if err := cmd.db.UpdateGuild(guild); err != nil {
+			return err
+		}
+	}
+
+	if len(guild.ChannelRoles) == 0 {
+		return respond("📢 Anyone who can manage a channel may create subscriptions that post in it.")
+	}
+
+	mentions := make([]string, 0, len(guild.ChannelRoles))
+	for _, id := range guild.ChannelRoles {
+		mentions = append(mentions, "<@&"+id+">")
+	}
+
+	return respond("📢 Members who can manage a channel and have one of these roles may create subscriptions that post in it: " + strings.Join(mentions, ", "))
+}
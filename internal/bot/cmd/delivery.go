@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robherley/sendibot/internal/db"
+)
+
+func NewDelivery(db db.DB) Handler {
+	return &Delivery{db}
+}
+
+type Delivery struct {
+	db db.DB
+}
+
+func (cmd *Delivery) Name() string {
+	return "delivery"
+}
+
+func (cmd *Delivery) Description() string {
+	return "Choose when new items are sent to you."
+}
+
+func (cmd *Delivery) Options() []*discordgo.ApplicationCommandOption {
+	minHour := float64(0)
+	maxHour := float64(23)
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "mode",
+			Description: "Send items as they are found, or collect them into a digest",
+			Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "Instant", Value: string(db.DeliveryInstant)},
+				{Name: "Hourly digest", Value: string(db.DeliveryHourly)},
+				{Name: "Daily digest", Value: string(db.DeliveryDaily)},
+			},
+			Required: false,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        "digest_hour",
+			Description: "Local hour (0-23) to send the daily digest at",
+			MinValue:    &minHour,
+			MaxValue:    maxHour,
+			Required:    false,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "timezone",
+			Description: "Your time zone, e.g. America/New_York or Asia/Tokyo",
+			Required:    false,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        "quiet_start",
+			Description: "Local hour (0-23) to start holding messages",
+			MinValue:    &minHour,
+			MaxValue:    maxHour,
+			Required:    false,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        "quiet_end",
+			Description: "Local hour (0-23) to stop holding messages",
+			MinValue:    &minHour,
+			MaxValue:    maxHour,
+			Required:    false,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionBoolean,
+			Name:        "clear_quiet",
+			Description: "Turn off quiet hours",
+			Required:    false,
+		},
+	}
+}
+
+func (cmd *Delivery) Handle(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return nil
+	}
+
+	userID := UserID(i)
+	if userID == "" {
+		return nil
+	}
+
+	user, err := cmd.db.GetUser(userID)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for _, option := range i.ApplicationCommandData().Options {
+		changed = true
+		switch option.Name {
+		case "mode":
+			user.DeliveryMode = db.DeliveryMode(option.StringValue())
+		case "digest_hour":
+			user.DigestHour = int(option.IntValue())
+		case "timezone":
+			tz := option.StringValue()
+			if _, err := time.LoadLocation(tz); err != nil || tz == "" || tz == "Local" {
+				return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+					Type: discordgo.InteractionResponseChannelMessageWithSource,
+					Data: &discordgo.InteractionResponseData{
+						Content: fmt.Sprintf("⛔ Unknown time zone: %q. Use a name like `America/New_York` or `Asia/Tokyo`.", tz),
+					},
+				})
+			}
+			user.Timezone = tz
+		case "quiet_start":
+			start := int(option.IntValue())
+			user.QuietStart = &start
+		case "quiet_end":
+			end := int(option.IntValue())
+			user.QuietEnd = &end
+		case "clear_quiet":
+			if option.BoolValue() {
+				user.QuietStart = nil
+				user.QuietEnd = nil
+			}
+		}
+	}
+
+	if (user.QuietStart == nil) != (user.QuietEnd == nil) {
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "⛔ Quiet hours need both a start and an end.",
+			},
+		})
+	}
+
+	if changed {
+		if err := cmd.db.UpdateUserSettings(user); err != nil {
+			return err
+		}
+	}
+
+	builder := strings.Builder{}
+	if changed {
+		builder.WriteString("✅ Updated your delivery settings:\n")
+	} else {
+		builder.WriteString("📬 Your delivery settings:\n")
+	}
+
+	builder.WriteString("- Mode: ")
+	switch user.DeliveryMode {
+	case db.DeliveryHourly:
+		builder.WriteString("hourly digest")
+	case db.DeliveryDaily:
+		builder.WriteString(fmt.Sprintf("daily digest at %02d:00", user.DigestHour))
+	default:
+		builder.WriteString("instant")
+	}
+
+	builder.WriteString("\n- Time zone: ")
+	builder.WriteString(user.Timezone)
+
+	builder.WriteString("\n- Quiet hours: ")
+	if user.QuietStart != nil && user.QuietEnd != nil {
+		builder.WriteString(fmt.Sprintf("%02d:00 - %02d:00", *user.QuietStart, *user.QuietEnd))
+	} else {
+		builder.WriteString("off")
+	}
+
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: builder.String(),
+		},
+	})
+}
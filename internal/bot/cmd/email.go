@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/mail"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robherley/sendibot/internal/db"
+)
+
+const (
+	// ConfirmCodeTTL is how long a confirm code is accepted for.
+	ConfirmCodeTTL = 15 * time.Minute
+	// MaxConfirmAttempts is the number of wrong codes after which the code stops being accepted.
+	MaxConfirmAttempts = 5
+)
+
+// ConfirmCodeSender sends the code that verifies an email address.
+type ConfirmCodeSender interface {
+	SendConfirmCode(ctx context.Context, address, code string) error
+}
+
+// Emails is how email delivery is set up, a nil *Emails means it is disabled.
+type Emails struct {
+	Sender ConfirmCodeSender
+}
+
+func NewEmail(db db.DB, emails *Emails) Handler {
+	return &Email{db, emails}
+}
+
+type Email struct {
+	db     db.DB
+	emails *Emails
+}
+
+func (cmd *Email) Name() string {
+	return "email"
+}
+
+func (cmd *Email) Description() string {
+	return "Set the email address new items can be sent to."
+}
+
+func (cmd *Email) Options() []*discordgo.ApplicationCommandOption {
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "set",
+			Description: "Send a confirm code to an address",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "address",
+					Description: "Email address to send new items to",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "confirm",
+			Description: "Verify your address with the code that was emailed to it",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "code",
+					Description: "The confirm code",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "remove",
+			Description: "Forget your email address",
+		},
+	}
+}
+
+func (cmd *Email) Handle(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return nil
+	}
+
+	userID := UserID(i)
+	if userID == "" {
+		return nil
+	}
+
+	options := i.ApplicationCommandData().Options
+	if len(options) == 0 {
+		return nil
+	}
+
+	respond := func(content string) error {
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+	}
+
+	email, err := cmd.db.GetEmail(userID)
+	if err != nil {
+		return err
+	}
+
+	switch sub := options[0]; sub.Name {
+	case "set":
+		address, err := mail.ParseAddress(sub.Options[0].StringValue())
+		if err != nil {
+			return respond("⛔ That doesn't look like an email address.")
+		}
+
+		code, err := confirmCode()
+		if err != nil {
+			return err
+		}
+
+		expires := time.Now().Add(ConfirmCodeTTL)
+		email.PendingAddress = address.Address
+		email.Code = code
+		email.CodeExpiresAt = &expires
+		email.CodeAttempts = 0
+		if err := cmd.db.UpdateEmail(email); err != nil {
+			return err
+		}
+
+		// sending can take longer than an interaction may go without a response
+		err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Flags: discordgo.MessageFlagsEphemeral,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		content := fmt.Sprintf("📧 Sent a confirm code to %s, use `/email confirm` with it within %s.", address.Address, FormatInterval(ConfirmCodeTTL))
+		if err := cmd.emails.Sender.SendConfirmCode(context.Background(), address.Address, code); err != nil {
+			slog.Error("failed to send confirm code", "err", err, "user_id", userID)
+			content = "⛔ I couldn't send an email to that address, check it and try again."
+		}
+
+		_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return err
+	case "confirm":
+		if email.PendingAddress == "" || email.CodeExpiresAt == nil || time.Now().After(*email.CodeExpiresAt) || email.CodeAttempts >= MaxConfirmAttempts {
+			return respond("⌛ There is no code waiting to be confirmed, use `/email set` to get a new one.")
+		}
+
+		code := sub.Options[0].StringValue()
+		if subtle.ConstantTimeCompare([]byte(code), []byte(email.Code)) != 1 {
+			email.CodeAttempts++
+			if err := cmd.db.UpdateEmail(email); err != nil {
+				return err
+			}
+			return respond(fmt.Sprintf("⛔ That code is wrong, %d attempt(s) left.", MaxConfirmAttempts-email.CodeAttempts))
+		}
+
+		email.Address = email.PendingAddress
+		email.PendingAddress = ""
+		email.Code = ""
+		email.CodeExpiresAt = nil
+		email.CodeAttempts = 0
+		if err := cmd.db.UpdateEmail(email); err != nil {
+			return err
+		}
+
+		return respond(fmt.Sprintf("✅ Verified %s! Use the `email` option of `/subscribe` to have new items emailed to you.", email.Address))
+	case "remove":
+		if err := cmd.db.DeleteEmail(userID); err != nil {
+			return err
+		}
+
+		return respond("🗑️ Forgot your email address.")
+	default:
+		return nil
+	}
+}
+
+// confirmCode returns a random six digit code.
+func confirmCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robherley/sendibot/internal/bot/emoji"
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/pkg/sendico"
+)
+
+// ItemEmbed renders an item as an embed with its image, price and shop.
+func ItemEmbed(emojis *emoji.Store, item sendico.Item) *discordgo.MessageEmbed {
+	// TODOs:
+	// - auction specific fields
+	// - translate???
+
+	shop := item.Shop.Name()
+	if emojis.Has(item.Shop.Identifier()) {
+		shop = emojis.For(item.Shop.Identifier()) + " " + shop
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: item.Name,
+		Image: &discordgo.MessageEmbedImage{
+			URL: item.Image,
+		},
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "Price",
+				Value:  fmt.Sprintf("¥%d ($%d)", item.PriceYen, item.PriceUSD),
+				Inline: true,
+			},
+			{
+				Name:   "Shop",
+				Value:  shop,
+				Inline: true,
+			},
+		},
+		URL: item.SendicoLink(),
+	}
+
+	if item.Category != nil {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "Category",
+			Value:  item.Category.String(),
+			Inline: true,
+		})
+	}
+
+	return embed
+}
+
+// DealEmbedField shows an item's deal score on its embed.
+func DealEmbedField(deal db.Deal) *discordgo.MessageEmbedField {
+	return &discordgo.MessageEmbedField{
+		Name:  "Deal score",
+		Value: DealLabel(deal),
+	}
+}
+
+// DealLabel describes an item's deal score, e.g. "🔥 32% below the median of ¥12000".
+func DealLabel(deal db.Deal) string {
+	if deal.Score == nil {
+		return "🌱 Not enough prices seen yet to score"
+	}
+	return fmt.Sprintf("🔥 %d%% below the median of ¥%d", *deal.Score, deal.MedianYen)
+}
+
+// FollowedEmbedField shows that an item is from a seller the user follows on its embed.
+func FollowedEmbedField(item sendico.Item) *discordgo.MessageEmbedField {
+	return &discordgo.MessageEmbedField{
+		Name:  "Seller",
+		Value: FollowedLabel(item),
+	}
+}
+
+// FollowedLabel describes that an item is from a seller the user follows, e.g. "⭐ From centerwave, a seller you
+// follow".
+func FollowedLabel(item sendico.Item) string {
+	sellerID, ok := item.SellerID()
+	if !ok {
+		// found by watching a seller on a shop whose items don't say who sells them
+		return "⭐ From a seller you follow"
+	}
+	return fmt.Sprintf("⭐ From %s, a seller you follow", sellerID)
+}
+
+// RelevanceEmbedField warns that an item is likely irrelevant on its embed.
+func RelevanceEmbedField(irrelevance int) *discordgo.MessageEmbedField {
+	return &discordgo.MessageEmbedField{
+		Name:  "Relevance",
+		Value: RelevanceLabel(irrelevance),
+	}
+}
+
+// RelevanceLabel describes how likely an item is irrelevant, e.g. "🤔 Probably not what you're after (82% going by
+// your votes)".
+func RelevanceLabel(irrelevance int) string {
+	return fmt.Sprintf("🤔 Probably not what you're after (%d%% going by your votes)", irrelevance)
+}
+
+// MaxVariantLines is the most duplicates listed on an item's embed, the rest are counted, so the field stays under
+// Discord's length limit.
+const MaxVariantLines = 8
+
+// VariantsEmbedField lists the duplicates collapsed into an item on its embed.
+func VariantsEmbedField(emojis *emoji.Store, variants []sendico.Item) *discordgo.MessageEmbedField {
+	lines := make([]string, 0, min(len(variants), MaxVariantLines)+1)
+	for _, variant := range variants[:min(len(variants), MaxVariantLines)] {
+		shop := variant.Shop.Name()
+		if emojis.Has(variant.Shop.Identifier()) {
+			shop = emojis.For(variant.Shop.Identifier()) + " " + shop
+		}
+		lines = append(lines, fmt.Sprintf("- %s [¥%d ($%d)](%s)", shop, variant.PriceYen, variant.PriceUSD, variant.SendicoLink()))
+	}
+	if len(variants) > MaxVariantLines {
+		lines = append(lines, fmt.Sprintf("- and %d more", len(variants)-MaxVariantLines))
+	}
+
+	return &discordgo.MessageEmbedField{
+		Name:  "Also listed",
+		Value: strings.Join(lines, "\n"),
+	}
+}
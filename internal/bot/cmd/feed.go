@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robherley/sendibot/internal/db"
+)
+
+// Feeds is how the Atom feeds of subscriptions are served, a nil *Feeds means they are disabled.
+type Feeds struct {
+	// URL returns the URL of the feed with the token.
+	URL func(token string) string
+}
+
+func NewFeed(db db.DB, feeds *Feeds) Handler {
+	return &Feed{db, feeds}
+}
+
+type Feed struct {
+	db    db.DB
+	feeds *Feeds
+}
+
+func (cmd *Feed) Name() string {
+	return "feed"
+}
+
+func (cmd *Feed) Description() string {
+	return "Get the private Atom feed URL of a subscription."
+}
+
+func (cmd *Feed) Handle(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	userID := UserID(i)
+	if userID == "" {
+		return nil
+	}
+
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		return cmd.handleMenu(s, i, userID, 0)
+	case discordgo.InteractionMessageComponent:
+		_, args := FromCustomID(i.MessageComponentData().CustomID)
+		if len(args) == 0 {
+			return nil
+		}
+		if page, ok := menuPage(args); ok {
+			return cmd.handleMenu(s, i, userID, page)
+		}
+
+		var (
+			subID string
+			reset bool
+		)
+		switch args[0] {
+		case "show":
+			if values := i.MessageComponentData().Values; len(values) > 0 {
+				subID = values[0]
+			}
+		case "reset":
+			if len(args) > 1 {
+				subID, reset = args[1], true
+			}
+		}
+
+		subscription, err := cmd.db.GetSubscription(subID)
+		if err != nil || subscription.UserID != userID {
+			return err
+		}
+
+		if subscription.FeedToken == nil || reset {
+			token, err := randomToken()
+			if err != nil {
+				return err
+			}
+
+			subscription.FeedToken = &token
+			if err := cmd.db.UpdateSubscription(subscription); err != nil {
+				return err
+			}
+		}
+
+		content := fmt.Sprintf("📰 Add this URL to your feed reader, anyone with it can see this subscription's matches:\n<%s>", cmd.feeds.URL(*subscription.FeedToken))
+		if reset {
+			content = "🔄 The old URL no longer works. " + content
+		}
+
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+				Flags:   discordgo.MessageFlagsEphemeral,
+				Components: []discordgo.MessageComponent{
+					discordgo.ActionsRow{
+						Components: []discordgo.MessageComponent{
+							discordgo.Button{
+								Label:    "Reset URL",
+								Style:    discordgo.DangerButton,
+								CustomID: cmd.Name() + ":reset:" + subscription.ID,
+								Emoji:    &discordgo.ComponentEmoji{Name: "🔄"},
+							},
+						},
+					},
+				},
+			},
+		})
+	default:
+		return nil
+	}
+}
+
+// handleMenu shows a page of the menu of subscriptions to get the feed of. The first page is sent as a new reply, paging
+// through it edits that reply in place.
+func (cmd *Feed) handleMenu(s *discordgo.Session, i *discordgo.InteractionCreate, userID string, page int) error {
+	responseType := discordgo.InteractionResponseChannelMessageWithSource
+	if i.Type == discordgo.InteractionMessageComponent {
+		responseType = discordgo.InteractionResponseUpdateMessage
+	}
+
+	subs, err := cmd.db.GetUserSubscriptions(userID)
+	if err != nil {
+		return err
+	}
+
+	if len(subs) == 0 {
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: responseType,
+			Data: &discordgo.InteractionResponseData{
+				Content:    "ℹ️ You have no subscriptions to get a feed for.",
+				Flags:      discordgo.MessageFlagsEphemeral,
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+	}
+
+	options := make([]discordgo.SelectMenuOption, 0, len(subs))
+	for _, sub := range subs {
+		options = append(options, discordgo.SelectMenuOption{
+			Label: sub.Term.EN,
+			Value: sub.Subscription.ID,
+		})
+	}
+
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: responseType,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: cmd.Name(),
+			Flags:    discordgo.MessageFlagsEphemeral,
+			Components: pagedSelectMenu(discordgo.SelectMenu{
+				CustomID:    cmd.Name() + ":show",
+				Placeholder: "📰 What subscription would you like the feed of?",
+			}, options, cmd.Name()+":page", page),
+		},
+	})
+}
+
+// randomToken returns a random token that can't be guessed, for feed URLs and webhook secrets.
+func randomToken() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
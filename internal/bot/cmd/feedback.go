@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/relevance"
+	"github.com/robherley/sendibot/pkg/sendico"
+)
+
+const (
+	// ItemsPerFeedbackRow is the number of items whose vote buttons share a row, discord allows 5 buttons per row and 5
+	// rows per message, so a full message of 10 items fits.
+	ItemsPerFeedbackRow = 2
+	// MaxSuggestedKeywords is the number of negative keywords suggested for each subscription.
+	MaxSuggestedKeywords = 3
+)
+
+// FeedbackComponents returns the 👍/👎 buttons of items, numbered from first like their embeds. Votes are handled by
+// /subscriptions, which suggests keywords from them.
+func FeedbackComponents(first int, items []sendico.Item) []discordgo.MessageComponent {
+	name := (&Subscriptions{}).Name()
+
+	var rows []discordgo.MessageComponent
+	for i := 0; i < len(items); i += ItemsPerFeedbackRow {
+		var buttons []discordgo.MessageComponent
+		for j, item := range items[i:min(i+ItemsPerFeedbackRow, len(items))] {
+			label := "#" + strconv.Itoa(first+i+j)
+			key := item.Shop.Identifier() + ":" + item.Code
+			buttons = append(buttons,
+				discordgo.Button{
+					Label:    label,
+					Style:    discordgo.SecondaryButton,
+					CustomID: name + ":vote:up:" + key,
+					Emoji:    &discordgo.ComponentEmoji{Name: "👍"},
+				},
+				discordgo.Button{
+					Label:    label,
+					Style:    discordgo.SecondaryButton,
+					CustomID: name + ":vote:down:" + key,
+					Emoji:    &discordgo.ComponentEmoji{Name: "👎"},
+				},
+			)
+		}
+		rows = append(rows, discordgo.ActionsRow{Components: buttons})
+	}
+
+	return rows
+}
+
+// handleVote records a vote on an item for the user's subscriptions that matched it.
+func (cmd *Subscriptions) handleVote(s *discordgo.Session, i *discordgo.InteractionCreate, userID string, args []string) error {
+	if len(args) < 4 {
+		return nil
+	}
+
+	vote := db.VoteUp
+	if args[1] == "down" {
+		vote = db.VoteDown
+	}
+
+	shop, ok := sendico.ShopMap[args[2]]
+	if !ok {
+		return nil
+	}
+
+	n, err := cmd.db.SaveFeedback(userID, shop, strings.Join(args[3:], ":"), vote)
+	if err != nil {
+		return err
+	}
+
+	var content string
+	switch {
+	case n == 0:
+		content = "⛔ That item isn't from any of your subscriptions."
+	case vote == db.VoteUp:
+		content = "👍 Thanks, I'll keep sending items like this one."
+	default:
+		content = "👎 Thanks, once I've learned from enough votes I'll flag or skip items like this one. Check `/subscriptions` for keywords to avoid."
+	}
+
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+// suggestKeywords returns the negative keywords mined from a subscription's down votes, as a line of /subscriptions, or
+// nothing if there aren't any.
+func (cmd *Subscriptions) suggestKeywords(subscriptionID string) (string, error) {
+	feedback, err := cmd.db.GetFeedback(subscriptionID)
+	if err != nil {
+		return "", err
+	}
+
+	keywords := relevance.Keywords(feedback, MaxSuggestedKeywords)
+	if len(keywords) == 0 {
+		return "", nil
+	}
+
+	return "  - 💡 you often 👎 items with " + quoteKeywords(keywords) + ", try excluding them\n", nil
+}
+
+func quoteKeywords(keywords []string) string {
+	quoted := make([]string, len(keywords))
+	for i, keyword := range keywords {
+		quoted[i] = "`" + keyword + "`"
+	}
+	return strings.Join(quoted, ", ")
+}
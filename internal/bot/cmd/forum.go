@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robherley/sendibot/internal/bot/emoji"
+	"github.com/robherley/sendibot/internal/db"
+)
+
+const (
+	// MaxThreadNameLength is the discord maximum length of a thread name.
+	MaxThreadNameLength = 100
+	// MaxAppliedTags is the discord maximum of tags applied to a forum post.
+	MaxAppliedTags = 5
+	// ThreadAutoArchive is how many minutes a subscription's thread may be idle before discord archives it. It is
+	// unarchived when new items are posted.
+	ThreadAutoArchive = 10080
+)
+
+// SubscriptionHeader renders a summary of a subscription's filters, it starts the subscription's forum thread.
+func SubscriptionHeader(emojis *emoji.Store, term *db.Term, sub *db.Subscription) *discordgo.MessageEmbed {
+	shops := make([]string, 0, len(sub.Shops()))
+	for _, shop := range sub.Shops() {
+		name := shop.Name()
+		if emojis.Has(shop.Identifier()) {
+			name = emojis.For(shop.Identifier()) + " " + name
+		}
+		shops = append(shops, name)
+	}
+
+	price := "Any"
+	switch {
+	case sub.MinPrice != nil && sub.MaxPrice != nil:
+		price = fmt.Sprintf("¥%d - ¥%d", *sub.MinPrice, *sub.MaxPrice)
+	case sub.MinPrice != nil:
+		price = fmt.Sprintf("¥%d or more", *sub.MinPrice)
+	case sub.MaxPrice != nil:
+		price = fmt.Sprintf("¥%d or less", *sub.MaxPrice)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("🔍 %q", term.EN),
+		Description: term.JP,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:  "Shops",
+				Value: strings.Join(shops, ", "),
+			},
+			{
+				Name:   "Price",
+				Value:  price,
+				Inline: true,
+			},
+			{
+				Name:   "Subscribed by",
+				Value:  fmt.Sprintf("<@%s>", sub.UserID),
+				Inline: true,
+			},
+		},
+	}
+
+	if sub.MinPollInterval != nil || sub.MaxPollInterval != nil {
+		interval := ""
+		if sub.MinPollInterval != nil {
+			interval += "at most every " + FormatInterval(*sub.MinPollInterval)
+		}
+		if sub.MaxPollInterval != nil {
+			if interval != "" {
+				interval += ", "
+			}
+			interval += "at least every " + FormatInterval(*sub.MaxPollInterval)
+		}
+
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "Checks",
+			Value:  interval,
+			Inline: true,
+		})
+	}
+
+	return embed
+}
+
+// startThread starts a post in a forum channel for the subscription, tagged with the forum's tags for its shops, and
+// pins the header that starts it.
+func startThread(s *discordgo.Session, forum *discordgo.Channel, emojis *emoji.Store, term *db.Term, sub *db.Subscription) (*discordgo.Channel, error) {
+	name := term.EN
+	if len(name) > MaxThreadNameLength {
+		name = strings.ToValidUTF8(name[:MaxThreadNameLength], "")
+	}
+
+	thread, err := s.ForumThreadStartComplex(forum.ID, &discordgo.ThreadStart{
+		Name:                name,
+		AutoArchiveDuration: ThreadAutoArchive,
+		AppliedTags:         shopTags(forum, sub),
+	}, &discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{SubscriptionHeader(emojis, term, sub)},
+		AllowedMentions: &discordgo.MessageAllowedMentions{
+			Parse: []discordgo.AllowedMentionType{},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the message that starts a forum post has the same ID as the post
+	if err := s.ChannelMessagePin(thread.ID, thread.ID); err != nil {
+		return thread, err
+	}
+
+	return thread, nil
+}
+
+// shopTags returns the IDs of the forum's tags that are named after the subscription's shops, by name or identifier.
+func shopTags(forum *discordgo.Channel, sub *db.Subscription) []string {
+	var tags []string
+	for _, shop := range sub.Shops() {
+		for _, tag := range forum.AvailableTags {
+			if !strings.EqualFold(tag.Name, shop.Name()) && !strings.EqualFold(tag.Name, shop.Identifier()) {
+				continue
+			}
+
+			if !slices.Contains(tags, tag.ID) && len(tags) < MaxAppliedTags {
+				tags = append(tags, tag.ID)
+			}
+		}
+	}
+	return tags
+}
+
+// SetThreadArchived archives or unarchives a subscription's forum thread, if it has one.
+func SetThreadArchived(s *discordgo.Session, sub *db.Subscription, archived bool) error {
+	if sub.ThreadID == nil {
+		return nil
+	}
+
+	_, err := s.ChannelEdit(*sub.ThreadID, &discordgo.ChannelEdit{
+		Archived: &archived,
+	})
+	return err
+}
@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -35,6 +37,16 @@ func ToApplicationCommand(h Handler) *discordgo.ApplicationCommand {
 		cmd.Options = h.Options()
 	}
 
+	if h, ok := h.(interface {
+		DefaultMemberPermissions() int64
+	}); ok {
+		// commands that need permissions are about managing a guild, so they don't make sense in DMs
+		permissions := h.DefaultMemberPermissions()
+		dmPermission := false
+		cmd.DefaultMemberPermissions = &permissions
+		cmd.DMPermission = &dmPermission
+	}
+
 	if cmd.Type != discordgo.ChatApplicationCommand {
 		// these are only allowed for chat commands
 		cmd.Description = ""
@@ -63,3 +75,24 @@ func UserID(i *discordgo.InteractionCreate) string {
 	}
 	return ""
 }
+
+// FormatInterval formats a duration to the minute, without the trailing zero units, e.g. "1h30m" or "2h".
+func FormatInterval(d time.Duration) string {
+	str := d.Round(time.Minute).String()
+	str = strings.TrimSuffix(str, "0s")
+	if strings.HasSuffix(str, "h0m") {
+		str = strings.TrimSuffix(str, "0m")
+	}
+	return str
+}
+
+var discordWebhookPattern = regexp.MustCompile(`^https://(?:(?:canary|ptb)\.)?discord(?:app)?\.com/api(?:/v\d+)?/webhooks/(\d+)/([\w-]+)/?$`)
+
+// ParseDiscordWebhook returns the ID and token of a Discord webhook URL.
+func ParseDiscordWebhook(webhookURL string) (id, token string, ok bool) {
+	match := discordWebhookPattern.FindStringSubmatch(webhookURL)
+	if match == nil {
+		return "", "", false
+	}
+	return match[1], match[2], true
+}
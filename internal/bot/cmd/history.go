@@ -0,0 +1,350 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robherley/sendibot/internal/bot/emoji"
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/pkg/sendico"
+)
+
+const (
+	// HistoryPageSize is the number of matches on each page of a search.
+	HistoryPageSize = 5
+	// HistoryTTL is how long a search can be paged through, the same as the lifetime of an interaction token.
+	HistoryTTL = 15 * time.Minute
+	// MaxAutocompleteChoices is the most choices Discord shows for an autocompleted option.
+	MaxAutocompleteChoices = 25
+)
+
+func NewHistory(db db.DB, emojis *emoji.Store) Handler {
+	return &History{
+		db:       db,
+		emojis:   emojis,
+		searches: make(map[string]search),
+	}
+}
+
+type History struct {
+	db     db.DB
+	emojis *emoji.Store
+
+	mu       sync.Mutex
+	searches map[string]search
+}
+
+// search is the filters of a /history command, kept in memory so they can be paged through with buttons.
+type search struct {
+	query   db.MatchQuery
+	expires time.Time
+}
+
+func (cmd *History) Name() string {
+	return "history"
+}
+
+func (cmd *History) Description() string {
+	return "Search the items your subscriptions alerted on."
+}
+
+func (cmd *History) Options() []*discordgo.ApplicationCommandOption {
+	minPrice := float64(0)
+	minDays := float64(1)
+
+	shops := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(sendico.Shops))
+	for _, shop := range sendico.Shops {
+		shops = append(shops, &discordgo.ApplicationCommandOptionChoice{
+			Name:  shop.Name(),
+			Value: shop.Identifier(),
+		})
+	}
+
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type:         discordgo.ApplicationCommandOptionString,
+			Name:         "subscription",
+			Description:  "Only search the items of this subscription",
+			Autocomplete: true,
+			Required:     false,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "query",
+			Description: "Text to look for in item names, in English or Japanese",
+			Required:    false,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "shop",
+			Description: "Only show items from this shop",
+			Choices:     shops,
+			Required:    false,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        "min",
+			Description: "Minimum price (¥)",
+			MinValue:    &minPrice,
+			Required:    false,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        "max",
+			Description: "Maximum price (¥)",
+			MinValue:    &minPrice,
+			Required:    false,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        "days",
+			Description: "Only show items that matched in the last N days",
+			MinValue:    &minDays,
+			Required:    false,
+		},
+	}
+}
+
+func (cmd *History) Handle(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	userID := UserID(i)
+	if userID == "" {
+		return nil
+	}
+
+	switch i.Type {
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		return cmd.handleAutocomplete(s, i, userID)
+	case discordgo.InteractionApplicationCommand:
+		query := db.MatchQuery{UserID: userID, Limit: HistoryPageSize}
+		var subscription string
+		for _, option := range i.ApplicationCommandData().Options {
+			switch option.Name {
+			case "subscription":
+				subscription = option.StringValue()
+			case "query":
+				query.Text = option.StringValue()
+			case "shop":
+				query.Shop = sendico.ShopMap[option.StringValue()]
+			case "min":
+				price := int(option.IntValue())
+				query.MinPrice = &price
+			case "max":
+				price := int(option.IntValue())
+				query.MaxPrice = &price
+			case "days":
+				query.Since = time.Now().AddDate(0, 0, -int(option.IntValue()))
+			}
+		}
+
+		if subscription != "" {
+			subID, err := cmd.findSubscription(userID, subscription)
+			if err != nil {
+				return err
+			}
+
+			if subID == "" {
+				return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+					Type: discordgo.InteractionResponseChannelMessageWithSource,
+					Data: &discordgo.InteractionResponseData{
+						Content: fmt.Sprintf("⛔ You have no subscription for %q.", subscription),
+						Flags:   discordgo.MessageFlagsEphemeral,
+					},
+				})
+			}
+			query.SubscriptionID = subID
+		}
+
+		searchID, err := cmd.storeSearch(query)
+		if err != nil {
+			return err
+		}
+
+		return cmd.handlePage(s, i, searchID, 0)
+	case discordgo.InteractionMessageComponent:
+		_, args := FromCustomID(i.MessageComponentData().CustomID)
+		if len(args) < 3 || args[0] != "page" {
+			return nil
+		}
+
+		page, _ := strconv.Atoi(args[2])
+		return cmd.handlePage(s, i, args[1], page)
+	}
+
+	return nil
+}
+
+// handleAutocomplete suggests the user's subscriptions whose term contains what they typed so far.
+func (cmd *History) handleAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate, userID string) error {
+	var typed string
+	for _, option := range i.ApplicationCommandData().Options {
+		if option.Focused {
+			typed = strings.ToLower(option.StringValue())
+		}
+	}
+
+	subs, err := cmd.db.GetUserSubscriptions(userID)
+	if err != nil {
+		return err
+	}
+
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, min(len(subs), MaxAutocompleteChoices))
+	for _, sub := range subs {
+		if len(choices) == MaxAutocompleteChoices {
+			break
+		}
+		if !strings.Contains(strings.ToLower(sub.Term.EN), typed) && !strings.Contains(sub.Term.JP, typed) {
+			continue
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  sub.Term.EN,
+			Value: sub.Subscription.ID,
+		})
+	}
+
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+}
+
+// findSubscription returns the ID of the user's subscription with the ID or term, which is what the option holds if
+// the user didn't pick a suggestion. It is empty if there is none.
+func (cmd *History) findSubscription(userID, value string) (string, error) {
+	subs, err := cmd.db.GetUserSubscriptions(userID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, sub := range subs {
+		if sub.Subscription.ID == value || strings.EqualFold(sub.Term.EN, value) {
+			return sub.Subscription.ID, nil
+		}
+	}
+
+	return "", nil
+}
+
+// handlePage shows a page of a search. The first page is sent as a new ephemeral reply, paging through it edits that
+// reply in place. Pages are queried when shown, so they include items that matched since the search.
+func (cmd *History) handlePage(s *discordgo.Session, i *discordgo.InteractionCreate, searchID string, page int) error {
+	responseType := discordgo.InteractionResponseChannelMessageWithSource
+	if i.Type == discordgo.InteractionMessageComponent {
+		responseType = discordgo.InteractionResponseUpdateMessage
+	}
+
+	query, ok := cmd.loadSearch(searchID)
+	if !ok || query.UserID != UserID(i) {
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: responseType,
+			Data: &discordgo.InteractionResponseData{
+				Content: "⌛ This search has expired, use `/history` again.",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+	}
+
+	query.Offset = max(0, page) * HistoryPageSize
+	matches, total, err := cmd.db.SearchMatches(query)
+	if err != nil {
+		return err
+	}
+
+	if total == 0 {
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: responseType,
+			Data: &discordgo.InteractionResponseData{
+				Content: "🔍 No past matches found.",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+	}
+
+	pages := (total + HistoryPageSize - 1) / HistoryPageSize
+	if page >= pages {
+		// the matches on this page were cleaned up since it was asked for, show the last one left
+		page = pages - 1
+		query.Offset = page * HistoryPageSize
+		if matches, _, err = cmd.db.SearchMatches(query); err != nil {
+			return err
+		}
+	}
+
+	embeds := make([]*discordgo.MessageEmbed, 0, len(matches))
+	for _, match := range matches {
+		embed := ItemEmbed(cmd.emojis, match.Item)
+		embed.Footer = &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("🔔 %s", match.Term.EN),
+		}
+		embed.Timestamp = match.MatchedAt.Format(time.RFC3339)
+		embeds = append(embeds, embed)
+	}
+
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: responseType,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("🗂️ Past matches (%d/%d), %d total", page+1, pages, total),
+			Embeds:  embeds,
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{
+							Label:    "Prev",
+							Style:    discordgo.SecondaryButton,
+							CustomID: cmd.Name() + ":page:" + searchID + ":" + strconv.Itoa(page-1),
+							Disabled: page == 0,
+						},
+						discordgo.Button{
+							Label:    "Next",
+							Style:    discordgo.SecondaryButton,
+							CustomID: cmd.Name() + ":page:" + searchID + ":" + strconv.Itoa(page+1),
+							Disabled: page == pages-1,
+						},
+					},
+				},
+			},
+		},
+	})
+}
+
+func (cmd *History) storeSearch(query db.MatchQuery) (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	searchID := hex.EncodeToString(id)
+
+	cmd.mu.Lock()
+	defer cmd.mu.Unlock()
+
+	now := time.Now()
+	for id, search := range cmd.searches {
+		if now.After(search.expires) {
+			delete(cmd.searches, id)
+		}
+	}
+
+	cmd.searches[searchID] = search{query: query, expires: now.Add(HistoryTTL)}
+	return searchID, nil
+}
+
+// loadSearch returns the filters of a search, false if it has expired.
+func (cmd *History) loadSearch(searchID string) (db.MatchQuery, bool) {
+	cmd.mu.Lock()
+	defer cmd.mu.Unlock()
+
+	search, ok := cmd.searches[searchID]
+	if !ok || time.Now().After(search.expires) {
+		return db.MatchQuery{}, false
+	}
+
+	return search.query, true
+}
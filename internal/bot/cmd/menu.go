@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// MaxSelectOptions is the most options discord allows in a select menu.
+const MaxSelectOptions = 25
+
+// pagedSelectMenu returns the components of a page of a select menu, discord only allows MaxSelectOptions options in a
+// menu so the rest are paged through with buttons. Their custom IDs are pageID followed by the page, e.g.
+// "pause:page:1". A menu with MaxValues set allows picking any of the options on the page. Pages past the last one
+// show the last one, so a menu whose options went away since it was shown still works.
+func pagedSelectMenu(menu discordgo.SelectMenu, options []discordgo.SelectMenuOption, pageID string, page int) []discordgo.MessageComponent {
+	pages := max(1, (len(options)+MaxSelectOptions-1)/MaxSelectOptions)
+	page = min(max(0, page), pages-1)
+
+	menu.Options = options[page*MaxSelectOptions : min(len(options), (page+1)*MaxSelectOptions)]
+	if menu.MaxValues > 0 {
+		menu.MaxValues = len(menu.Options)
+	}
+	if pages == 1 {
+		return []discordgo.MessageComponent{discordgo.ActionsRow{Components: []discordgo.MessageComponent{menu}}}
+	}
+
+	menu.Placeholder += fmt.Sprintf(" (%d/%d)", page+1, pages)
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{menu}},
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Prev",
+					Style:    discordgo.SecondaryButton,
+					CustomID: pageID + ":" + strconv.Itoa(page-1),
+					Disabled: page == 0,
+				},
+				discordgo.Button{
+					Label:    "Next",
+					Style:    discordgo.SecondaryButton,
+					CustomID: pageID + ":" + strconv.Itoa(page+1),
+					Disabled: page == pages-1,
+				},
+			},
+		},
+	}
+}
+
+// menuPage returns the page a component asks for if it is a paging button of pagedSelectMenu, whose custom ID is
+// "<cmd>:page:<page>".
+func menuPage(args []string) (int, bool) {
+	if len(args) < 2 || args[0] != "page" {
+		return 0, false
+	}
+
+	page, err := strconv.Atoi(args[1])
+	return page, err == nil
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/notify"
+)
+
+// PushTester publishes a test notification to a push server.
+type PushTester interface {
+	SendTest(ctx context.Context, target *db.Push) error
+}
+
+// Pushes is how push delivery is set up, a nil *Pushes means it is disabled.
+type Pushes struct {
+	Tester PushTester
+}
+
+func NewNotify(db db.DB, pushes *Pushes) Handler {
+	return &Notify{db, pushes}
+}
+
+type Notify struct {
+	db     db.DB
+	pushes *Pushes
+}
+
+func (cmd *Notify) Name() string {
+	return "notify"
+}
+
+func (cmd *Notify) Description() string {
+	return "Set the ntfy or Gotify server new items can be pushed to."
+}
+
+func (cmd *Notify) Options() []*discordgo.ApplicationCommandOption {
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "ntfy",
+			Description: "Push to an ntfy topic",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "server",
+					Description: "URL of the ntfy server, e.g. https://ntfy.sh",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "topic",
+					Description: "Topic to publish to",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "token",
+					Description: "Access token, if the topic is protected",
+					Required:    false,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "gotify",
+			Description: "Push to a Gotify app",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "server",
+					Description: "URL of the Gotify server",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "token",
+					Description: "Token of the app to publish as",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "test",
+			Description: "Push a test notification",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "off",
+			Description: "Forget your push server",
+		},
+	}
+}
+
+func (cmd *Notify) Handle(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return nil
+	}
+
+	userID := UserID(i)
+	if userID == "" {
+		return nil
+	}
+
+	options := i.ApplicationCommandData().Options
+	if len(options) == 0 {
+		return nil
+	}
+
+	respond := func(content string) error {
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+	}
+
+	push := &db.Push{UserID: userID}
+	switch sub := options[0]; sub.Name {
+	case "ntfy", "gotify":
+		push.Kind = db.PushKind(sub.Name)
+		for _, option := range sub.Options {
+			switch option.Name {
+			case "server":
+				push.ServerURL = option.StringValue()
+			case "topic":
+				push.Topic = option.StringValue()
+			case "token":
+				push.Token = option.StringValue()
+			}
+		}
+
+		// the push notifier checks again when it connects, in case the host is pointed somewhere else later
+		if err := notify.CheckPublicURL(context.Background(), push.ServerURL); err != nil {
+			return respond("⛔ Invalid server: " + err.Error() + ".")
+		}
+
+		if err := cmd.db.UpdatePush(push); err != nil {
+			return err
+		}
+	case "test":
+		var err error
+		if push, err = cmd.db.GetPush(userID); err != nil {
+			return err
+		}
+
+		if !push.IsSet() {
+			return respond("ℹ️ You haven't set a push server, use `/notify ntfy` or `/notify gotify`.")
+		}
+	case "off":
+		if err := cmd.db.DeletePush(userID); err != nil {
+			return err
+		}
+
+		return respond("🔕 Forgot your push server.")
+	default:
+		return nil
+	}
+
+	// the test can take longer than an interaction may go without a response
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	content := fmt.Sprintf("📲 Pushed a test notification to your %s server. Use the `push` option of `/subscribe` to have new items pushed to it.", push.Kind)
+	if err := cmd.pushes.Tester.SendTest(context.Background(), push); err != nil {
+		slog.Error("failed to push test notification", "err", err, "user_id", userID)
+		content = fmt.Sprintf("⛔ I couldn't push to your %s server, check its settings: %s", push.Kind, err)
+	}
+
+	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
+	})
+	return err
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robherley/sendibot/internal/db"
+)
+
+// NewPause returns the command that pauses subscriptions, nothing is searched for or sent until they are resumed.
+func NewPause(db db.DB) Handler {
+	return &Pause{db: db}
+}
+
+// NewResume returns the command that resumes paused subscriptions.
+func NewResume(db db.DB) Handler {
+	return &Pause{db: db, resume: true}
+}
+
+// Pause pauses or resumes subscriptions, archiving or unarchiving their forum threads along with them.
+type Pause struct {
+	db     db.DB
+	resume bool
+}
+
+func (cmd *Pause) Name() string {
+	if cmd.resume {
+		return "resume"
+	}
+	return "pause"
+}
+
+func (cmd *Pause) Description() string {
+	if cmd.resume {
+		return "Resume paused subscription(s)."
+	}
+	return "Pause subscription(s) without removing them."
+}
+
+func (cmd *Pause) Handle(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	userID := UserID(i)
+	if userID == "" {
+		return nil
+	}
+
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		return cmd.handleMenu(s, i, userID, 0)
+	case discordgo.InteractionMessageComponent:
+		_, args := FromCustomID(i.MessageComponentData().CustomID)
+		if page, ok := menuPage(args); ok {
+			return cmd.handleMenu(s, i, userID, page)
+		}
+
+		subIDs := i.MessageComponentData().Values
+
+		subs, err := cmd.db.GetUserSubscriptions(userID)
+		if err != nil {
+			return err
+		}
+
+		count := 0
+		for _, sub := range subs {
+			if !slices.Contains(subIDs, sub.Subscription.ID) || sub.Subscription.IsPaused() != cmd.resume {
+				continue
+			}
+
+			if cmd.resume {
+				err = cmd.db.ResumeSubscription(sub.Subscription.ID)
+			} else {
+				err = cmd.db.PauseSubscription(sub.Subscription.ID, db.PauseReasonUser)
+			}
+			if err != nil {
+				return err
+			}
+			count++
+
+			if err := SetThreadArchived(s, &sub.Subscription, !cmd.resume); err != nil {
+				slog.Error("failed to update forum thread", "err", err, "thread_id", *sub.Subscription.ThreadID, "sub_id", sub.Subscription.ID)
+				// the subscription is still paused or resumed
+			}
+		}
+
+		content := fmt.Sprintf("⏸️ Paused %d subscription(s)! Use `/resume` to pick them back up.", count)
+		if cmd.resume {
+			content = fmt.Sprintf("▶️ Resumed %d subscription(s)!", count)
+		}
+
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+			},
+		})
+	default:
+		return nil
+	}
+}
+
+// handleMenu shows a page of the menu of subscriptions to pause or resume. The first page is sent as a new reply, paging
+// through it edits that reply in place.
+func (cmd *Pause) handleMenu(s *discordgo.Session, i *discordgo.InteractionCreate, userID string, page int) error {
+	responseType := discordgo.InteractionResponseChannelMessageWithSource
+	if i.Type == discordgo.InteractionMessageComponent {
+		responseType = discordgo.InteractionResponseUpdateMessage
+	}
+
+	subs, err := cmd.db.GetUserSubscriptions(userID)
+	if err != nil {
+		return err
+	}
+
+	options := make([]discordgo.SelectMenuOption, 0, len(subs))
+	for _, sub := range subs {
+		if sub.Subscription.IsPaused() != cmd.resume {
+			continue
+		}
+
+		options = append(options, discordgo.SelectMenuOption{
+			Label: sub.Term.EN,
+			Value: sub.Subscription.ID,
+		})
+	}
+
+	if len(options) == 0 {
+		content := "ℹ️ You have no active subscriptions to pause."
+		if cmd.resume {
+			content = "ℹ️ You have no paused subscriptions to resume."
+		}
+
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: responseType,
+			Data: &discordgo.InteractionResponseData{
+				Content:    content,
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+	}
+
+	placeholder := "⏸️ What subscription(s) would you like to pause?"
+	if cmd.resume {
+		placeholder = "▶️ What subscription(s) would you like to resume?"
+	}
+
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: responseType,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: cmd.Name(),
+			Components: pagedSelectMenu(discordgo.SelectMenu{
+				CustomID:    cmd.Name() + ":select",
+				Placeholder: placeholder,
+				MaxValues:   len(options),
+			}, options, cmd.Name()+":page", page),
+		},
+	})
+}
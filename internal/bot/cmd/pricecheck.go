@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robherley/sendibot/internal/bot/emoji"
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/pricing"
+	"github.com/robherley/sendibot/pkg/sendico"
+)
+
+const (
+	// PriceCheckWindow is how far back stored listings are included in a price check.
+	PriceCheckWindow = 30 * 24 * time.Hour
+	// HistogramBuckets is the number of rows in a price check's histogram.
+	HistogramBuckets = 6
+	// HistogramWidth is the number of characters in the longest bar of a price check's histogram.
+	HistogramWidth = 12
+)
+
+func NewPriceCheck(db db.DB, sendico *sendico.Client, emojis *emoji.Store) Handler {
+	return &PriceCheck{db, sendico, emojis}
+}
+
+type PriceCheck struct {
+	db      db.DB
+	sendico *sendico.Client
+	emojis  *emoji.Store
+}
+
+func (cmd *PriceCheck) Name() string {
+	return "pricecheck"
+}
+
+func (cmd *PriceCheck) Description() string {
+	return "See what items usually sell for, from a search now and the listings seen in the last 30 days."
+}
+
+func (cmd *PriceCheck) Options() []*discordgo.ApplicationCommandOption {
+	termMinLength := 1
+	termMaxLength := 100
+
+	shops := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(sendico.Shops))
+	for _, shop := range sendico.Shops {
+		shops = append(shops, &discordgo.ApplicationCommandOptionChoice{
+			Name:  shop.Name(),
+			Value: shop.Identifier(),
+		})
+	}
+
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "term",
+			Description: "What items do you want prices for?",
+			MinLength:   &termMinLength,
+			MaxLength:   termMaxLength,
+			Required:    true,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "shop",
+			Description: "Only check this shop, all of them if not set",
+			Choices:     shops,
+			Required:    false,
+		},
+	}
+}
+
+func (cmd *PriceCheck) Handle(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return nil
+	}
+
+	var (
+		termEN string
+		shops  = sendico.Shops
+	)
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "term":
+			termEN = strings.TrimSpace(option.StringValue())
+		case "shop":
+			if shop, ok := sendico.ShopMap[option.StringValue()]; ok {
+				shops = []sendico.Shop{shop}
+			}
+		}
+	}
+
+	// searching every shop can take longer than an interaction may go without a response
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	embed, err := cmd.check(termEN, shops)
+	if err != nil {
+		slog.Error("failed to check prices", "err", err, "term", termEN)
+		content := "⛔ I couldn't check prices right now, try again later."
+		_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: &content,
+		})
+		return err
+	}
+
+	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+	return err
+}
+
+// check searches the shops for the term, stores what it found like a subscription's search would, and summarizes the
+// prices of every listing of the term seen within PriceCheckWindow.
+func (cmd *PriceCheck) check(termEN string, shops []sendico.Shop) (*discordgo.MessageEmbed, error) {
+	ctx := context.Background()
+
+	termJP, err := cmd.sendico.Translate(ctx, termEN)
+	if err != nil {
+		return nil, err
+	}
+
+	term := db.Term{EN: termEN, JP: termJP}
+	if err := cmd.db.CreateTerm(&term); err != nil {
+		return nil, err
+	}
+
+	results, err := cmd.sendico.BulkSearch(ctx, shops, sendico.SearchOptions{
+		TermJP: term.JP,
+		Sort:   sendico.SortNewest,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.db.SaveSnapshots(term.ID, results...); err != nil {
+		return nil, err
+	}
+
+	prices, err := cmd.db.GetTermPrices(term.ID, time.Now().Add(-PriceCheckWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	total := 0
+	embed := &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("💴 Prices for %q (%s)", term.EN, term.JP),
+	}
+	for _, shop := range shops {
+		shopPrices := prices[shop]
+		if len(shopPrices) == 0 {
+			continue
+		}
+		total += len(shopPrices)
+
+		name := shop.Name()
+		if cmd.emojis.Has(shop.Identifier()) {
+			name = cmd.emojis.For(shop.Identifier()) + " " + name
+		}
+
+		stats := pricing.Summarize(shopPrices)
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: fmt.Sprintf("%s (%d)", name, stats.Count),
+			Value: fmt.Sprintf("Min ¥%d · P25 ¥%d · **Median ¥%d** · P75 ¥%d · Max ¥%d\n```\n%s```",
+				stats.Min, stats.P25, stats.Median, stats.P75, stats.Max, histogram(pricing.Histogram(shopPrices, HistogramBuckets))),
+		})
+	}
+
+	if total == 0 {
+		embed.Description = "No listings found, try another term."
+	} else {
+		embed.Description = fmt.Sprintf("%d listing(s) over the last 30 days, %d found just now.", total, len(results))
+	}
+
+	return embed, nil
+}
+
+// histogram renders buckets as rows of bars, scaled so the fullest bucket's is HistogramWidth long.
+func histogram(buckets []pricing.Bucket) string {
+	most := 0
+	for _, bucket := range buckets {
+		most = max(most, bucket.Count)
+	}
+
+	labels := make([]string, len(buckets))
+	labelWidth := 0
+	for i, bucket := range buckets {
+		labels[i] = fmt.Sprintf("¥%d–%d", bucket.Low, bucket.High)
+		labelWidth = max(labelWidth, len([]rune(labels[i])))
+	}
+
+	var b strings.Builder
+	for i, bucket := range buckets {
+		bar := (bucket.Count*HistogramWidth + most - 1) / most
+		fmt.Fprintf(&b, "%s%s %s %d\n", labels[i], strings.Repeat(" ", labelWidth-len([]rune(labels[i]))), strings.Repeat("█", bar), bucket.Count)
+	}
+
+	return b.String()
+}
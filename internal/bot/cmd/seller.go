@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robherley/sendibot/internal/bot/emoji"
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/pkg/sendico"
+)
+
+func NewSeller(db db.DB, sendico *sendico.Client, emojis *emoji.Store) Handler {
+	return &Seller{db, sendico, emojis}
+}
+
+type Seller struct {
+	db      db.DB
+	sendico *sendico.Client
+	emojis  *emoji.Store
+}
+
+func (cmd *Seller) Name() string {
+	return "seller"
+}
+
+func (cmd *Seller) Description() string {
+	return "Follow or block sellers across all your subscriptions."
+}
+
+func (cmd *Seller) Options() []*discordgo.ApplicationCommandOption {
+	link := []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "link",
+			Description: "A link to the seller's store or Mercari profile, or to one of their items on the shop or Sendico",
+			Required:    true,
+		},
+	}
+
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "follow",
+			Description: "Get every new item a seller lists, and every item from them your subscriptions find",
+			Options:     link,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "block",
+			Description: "Never get items from a seller",
+			Options:     link,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "list",
+			Description: "Show the sellers you follow and block",
+		},
+	}
+}
+
+func (cmd *Seller) Handle(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	userID := UserID(i)
+	if userID == "" {
+		return nil
+	}
+
+	respond := func(content string, components ...discordgo.MessageComponent) error {
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content:    content,
+				Components: components,
+				Flags:      discordgo.MessageFlagsEphemeral,
+			},
+		})
+	}
+
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		data := i.ApplicationCommandData()
+		if len(data.Options) == 0 {
+			return nil
+		}
+
+		subcommand := data.Options[0]
+		switch subcommand.Name {
+		case "follow", "block":
+			if len(subcommand.Options) == 0 {
+				return nil
+			}
+
+			shop, sellerID, ok := sendico.ParseSeller(subcommand.Options[0].StringValue())
+			if !ok {
+				if shop != 0 {
+					return respond(fmt.Sprintf("⛔ %s doesn't say who sells its items, only Mercari, Rakuten and Yahoo Shopping sellers can be followed.", shop.Name()))
+				}
+				return respond("⛔ That isn't a link to a Mercari profile, a Rakuten or Yahoo Shopping store or item, or to one on Sendico.")
+			}
+
+			seller := db.Seller{Shop: shop, ID: sellerID, Status: db.SellerFollowed}
+			if subcommand.Name == "block" {
+				if !shop.HasSellers() {
+					return respond(fmt.Sprintf("⛔ %s doesn't say who sells its items, so its sellers can be followed but not blocked.", shop.Name()))
+				}
+				seller.Status = db.SellerBlocked
+			}
+
+			if err := cmd.db.SaveSeller(userID, seller); err != nil {
+				return err
+			}
+
+			if seller.Status == db.SellerBlocked {
+				if err := cmd.unwatch(userID, seller); err != nil {
+					return err
+				}
+				return respond(fmt.Sprintf("🚫 Blocked %s, you won't get its items on any of your subscriptions.", cmd.label(seller)))
+			}
+
+			if err := cmd.watch(userID, seller); err != nil {
+				return err
+			}
+			return respond(fmt.Sprintf("⭐ Following %s, you'll get every new item it lists, and every item from it your subscriptions find.", cmd.label(seller)))
+		case "list":
+			return cmd.handleList(userID, respond)
+		}
+	case discordgo.InteractionMessageComponent:
+		_, args := FromCustomID(i.MessageComponentData().CustomID)
+		if len(args) == 0 || args[0] != "forget" {
+			return nil
+		}
+
+		forgotten := 0
+		for _, value := range i.MessageComponentData().Values {
+			identifier, sellerID, _ := strings.Cut(value, ":")
+			shop, ok := sendico.ShopMap[identifier]
+			if !ok {
+				continue
+			}
+
+			if err := cmd.db.DeleteSeller(userID, shop, sellerID); err != nil {
+				return err
+			}
+			if err := cmd.unwatch(userID, db.Seller{Shop: shop, ID: sellerID}); err != nil {
+				return err
+			}
+			forgotten++
+		}
+
+		return respond(fmt.Sprintf("✅ Forgot %d seller(s)!", forgotten))
+	}
+
+	return nil
+}
+
+// watch subscribes the user to the seller's listings, with a term of its own so it can be polled like any other
+// subscription. The items listed right now are marked as seen, only ones listed after following are sent.
+func (cmd *Seller) watch(userID string, seller db.Seller) error {
+	term := &db.Term{EN: fmt.Sprintf("%s seller %s", seller.Shop.Name(), seller.ID)}
+	if err := cmd.db.CreateTerm(term); err != nil {
+		return err
+	}
+
+	subscription := &db.Subscription{UserID: userID, TermID: term.ID, SellerID: &seller.ID}
+	subscription.AddShop(seller.Shop)
+	if err := cmd.db.CreateSubscription(subscription); err != nil {
+		if errors.Is(err, db.ErrConstraintUnique) {
+			// already watching, e.g. the seller was followed again
+			return nil
+		}
+		return err
+	}
+
+	if _, err := seedCurrentItems(cmd.db, cmd.sendico, term, subscription); err != nil {
+		slog.Error("failed to seed current items", "err", err, "sub_id", subscription.ID)
+		// this is best effort
+	}
+
+	return nil
+}
+
+// unwatch removes the user's subscription to the seller's listings, if they have one.
+func (cmd *Seller) unwatch(userID string, seller db.Seller) error {
+	subs, err := cmd.db.GetUserSubscriptions(userID)
+	if err != nil {
+		return err
+	}
+
+	var ids []string
+	for _, sub := range subs {
+		if sub.Subscription.SellerID != nil && *sub.Subscription.SellerID == seller.ID && sub.Subscription.ShopsBitField == int(seller.Shop) {
+			ids = append(ids, sub.Subscription.ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+	return cmd.db.DeleteUserSubscriptions(userID, ids...)
+}
+
+// handleList lists the user's sellers, with a menu to forget them.
+func (cmd *Seller) handleList(userID string, respond func(string, ...discordgo.MessageComponent) error) error {
+	sellers, err := cmd.db.GetSellers(userID)
+	if err != nil {
+		return err
+	}
+
+	if len(sellers) == 0 {
+		return respond("ℹ️ You don't follow or block any sellers. Use `/seller follow` or `/seller block` with a link to one.")
+	}
+
+	builder := strings.Builder{}
+	options := make([]discordgo.SelectMenuOption, 0, min(len(sellers), MaxSelectOptions))
+	for _, status := range []db.SellerStatus{db.SellerFollowed, db.SellerBlocked} {
+		heading := "⭐ Following:\n"
+		if status == db.SellerBlocked {
+			heading = "🚫 Blocked:\n"
+		}
+
+		for _, seller := range sellers {
+			if seller.Status != status {
+				continue
+			}
+
+			builder.WriteString(heading)
+			heading = ""
+			builder.WriteString("- ")
+			builder.WriteString(cmd.label(seller))
+			builder.WriteString("\n")
+
+			if len(options) < MaxSelectOptions {
+				options = append(options, discordgo.SelectMenuOption{
+					Label: fmt.Sprintf("%s (%s)", seller.ID, seller.Shop.Name()),
+					Value: seller.Key(),
+				})
+			}
+		}
+	}
+
+	return respond(builder.String(), discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.SelectMenu{
+				CustomID:    cmd.Name() + ":forget",
+				Placeholder: "🗑️ What seller(s) would you like to forget?",
+				Options:     options,
+				MaxValues:   len(options),
+			},
+		},
+	})
+}
+
+// label formats a seller with their shop, e.g. "`centerwave` on Rakuten".
+func (cmd *Seller) label(seller db.Seller) string {
+	shop := seller.Shop.Name()
+	if cmd.emojis.Has(seller.Shop.Identifier()) {
+		shop = cmd.emojis.For(seller.Shop.Identifier()) + " " + shop
+	}
+	return fmt.Sprintf("`%s` on %s", seller.ID, shop)
+}
@@ -5,23 +5,63 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/robherley/sendibot/internal/bot/emoji"
 	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/looper"
+	"github.com/robherley/sendibot/internal/notify"
 	"github.com/robherley/sendibot/pkg/sendico"
 )
 
-func NewSubscribe(db db.DB, sendico *sendico.Client, emojis *emoji.Store) Handler {
-	return &Subscribe{db, sendico, emojis, nil}
+const (
+	// PreviewTTL is how long the listings found when subscribing can be previewed or queued, the same as the lifetime
+	// of an interaction token.
+	PreviewTTL = 15 * time.Minute
+	// PreviewPageSize is the number of listings on each page of a preview.
+	PreviewPageSize = 5
+)
+
+// Webhooks is how webhook delivery is set up, a nil *Webhooks means it is disabled.
+type Webhooks struct {
+	// HasDefault is set if there is a globally configured URL that subscriptions can post to.
+	HasDefault bool
+}
+
+func NewSubscribe(db db.DB, sendico *sendico.Client, emojis *emoji.Store, webhooks *Webhooks, emails *Emails, pushes *Pushes) Handler {
+	return &Subscribe{
+		db:       db,
+		sendico:  sendico,
+		emojis:   emojis,
+		webhooks: webhooks,
+		emails:   emails,
+		pushes:   pushes,
+		previews: make(map[string]preview),
+	}
 }
 
 type Subscribe struct {
-	db      db.DB
-	sendico *sendico.Client
-	emojis  *emoji.Store
-	opts    []discordgo.SelectMenuOption
+	db       db.DB
+	sendico  *sendico.Client
+	emojis   *emoji.Store
+	webhooks *Webhooks
+	emails   *Emails
+	pushes   *Pushes
+	opts     []discordgo.SelectMenuOption
+
+	mu       sync.Mutex
+	previews map[string]preview
+}
+
+// preview is the listings that were up when a subscription was created, they are kept in memory for a short while.
+type preview struct {
+	items   []sendico.Item
+	expires time.Time
+	queued  bool
 }
 
 func (cmd *Subscribe) Name() string {
@@ -35,7 +75,10 @@ func (cmd *Subscribe) Description() string {
 func (cmd *Subscribe) Options() []*discordgo.ApplicationCommandOption {
 	termMinLength := 1
 	termMaxLength := 100
-	return []*discordgo.ApplicationCommandOption{
+	minInterval := float64(5)
+	minDeal := float64(1)
+	maxDeal := float64(90)
+	options := []*discordgo.ApplicationCommandOption{
 		{
 			Type:        discordgo.ApplicationCommandOptionString,
 			Name:        "search",
@@ -56,7 +99,81 @@ func (cmd *Subscribe) Options() []*discordgo.ApplicationCommandOption {
 			Description: "Maximum price (¥) to alert on",
 			Required:    false,
 		},
+		{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        "deal",
+			Description: "Only alert on items at least this % below the usual price",
+			MinValue:    &minDeal,
+			MaxValue:    maxDeal,
+			Required:    false,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        "min_interval",
+			Description: "Check no more often than every N minutes",
+			MinValue:    &minInterval,
+			Required:    false,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        "max_interval",
+			Description: "Check at least every N minutes",
+			MinValue:    &minInterval,
+			Required:    false,
+		},
+	}
+
+	options = append(options,
+		&discordgo.ApplicationCommandOption{
+			Type:         discordgo.ApplicationCommandOptionChannel,
+			Name:         "channel",
+			Description:  "Post new items in this channel instead of your DMs, or in a post of its own in a forum",
+			ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText, discordgo.ChannelTypeGuildNews, discordgo.ChannelTypeGuildForum},
+			Required:     false,
+		},
+		&discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "discord_webhook",
+			Description: "Post new items through this Discord webhook URL instead of your DMs",
+			Required:    false,
+		},
+	)
+
+	if cmd.webhooks != nil {
+		options = append(options, &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "webhook",
+			Description: "URL to also post new items to as JSON, or \"default\" for the bot's webhook",
+			Required:    false,
+		})
+	}
+
+	if cmd.emails != nil {
+		options = append(options, &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionBoolean,
+			Name:        "email",
+			Description: "Also email new items to the address you set with /email",
+			Required:    false,
+		})
+	}
+
+	if cmd.pushes != nil {
+		options = append(options, &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        "push",
+			Description: "Also push new items to the server you set with /notify, with this priority",
+			Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "Min", Value: 1},
+				{Name: "Low", Value: 2},
+				{Name: "Default", Value: 3},
+				{Name: "High", Value: 4},
+				{Name: "Urgent", Value: 5},
+			},
+			Required: false,
+		})
 	}
+
+	return options
 }
 
 func (cmd *Subscribe) Handle(s *discordgo.Session, i *discordgo.InteractionCreate) error {
@@ -68,6 +185,14 @@ func (cmd *Subscribe) Handle(s *discordgo.Session, i *discordgo.InteractionCreat
 			searchTermEN string
 			minPrice     *int
 			maxPrice     *int
+			minInterval  *time.Duration
+			maxInterval  *time.Duration
+			webhook      *string
+			channelID    *string
+			discordHook  *string
+			email        bool
+			pushPriority *int
+			deal         *int
 		)
 
 		for _, option := range data.Options {
@@ -80,6 +205,29 @@ func (cmd *Subscribe) Handle(s *discordgo.Session, i *discordgo.InteractionCreat
 			case "max":
 				max := int(option.IntValue())
 				maxPrice = &max
+			case "min_interval":
+				min := time.Duration(option.IntValue()) * time.Minute
+				minInterval = &min
+			case "max_interval":
+				max := time.Duration(option.IntValue()) * time.Minute
+				maxInterval = &max
+			case "webhook":
+				value := option.StringValue()
+				webhook = &value
+			case "channel":
+				id := option.ChannelValue(nil).ID
+				channelID = &id
+			case "discord_webhook":
+				value := option.StringValue()
+				discordHook = &value
+			case "email":
+				email = option.BoolValue()
+			case "push":
+				priority := int(option.IntValue())
+				pushPriority = &priority
+			case "deal":
+				threshold := int(option.IntValue())
+				deal = &threshold
 			}
 		}
 
@@ -94,6 +242,77 @@ func (cmd *Subscribe) Handle(s *discordgo.Session, i *discordgo.InteractionCreat
 			}
 		}
 
+		if minInterval != nil && maxInterval != nil {
+			if *minInterval > *maxInterval {
+				return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+					Type: discordgo.InteractionResponseChannelMessageWithSource,
+					Data: &discordgo.InteractionResponseData{
+						Content: "⛔ Minimum interval must be less than or equal to maximum interval.",
+					},
+				})
+			}
+		}
+
+		var webhookURL *string
+		if webhook != nil {
+			var err error
+			if webhookURL, err = cmd.webhookURL(*webhook); err != nil {
+				return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+					Type: discordgo.InteractionResponseChannelMessageWithSource,
+					Data: &discordgo.InteractionResponseData{
+						Content: "⛔ Invalid webhook: " + err.Error() + ".",
+						Flags:   discordgo.MessageFlagsEphemeral,
+					},
+				})
+			}
+		}
+
+		if problem, err := cmd.checkTarget(s, i, channelID, discordHook); err != nil || problem != "" {
+			if err != nil {
+				return err
+			}
+
+			return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "⛔ " + problem,
+					Flags:   discordgo.MessageFlagsEphemeral,
+				},
+			})
+		}
+
+		if email {
+			if problem, err := cmd.checkEmail(UserID(i)); err != nil || problem != "" {
+				if err != nil {
+					return err
+				}
+
+				return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+					Type: discordgo.InteractionResponseChannelMessageWithSource,
+					Data: &discordgo.InteractionResponseData{
+						Content: "⛔ " + problem,
+						Flags:   discordgo.MessageFlagsEphemeral,
+					},
+				})
+			}
+		}
+
+		if pushPriority != nil {
+			if problem, err := cmd.checkPush(UserID(i)); err != nil || problem != "" {
+				if err != nil {
+					return err
+				}
+
+				return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+					Type: discordgo.InteractionResponseChannelMessageWithSource,
+					Data: &discordgo.InteractionResponseData{
+						Content: "⛔ " + problem,
+						Flags:   discordgo.MessageFlagsEphemeral,
+					},
+				})
+			}
+		}
+
 		searchTermJP, err := cmd.sendico.Translate(context.Background(), searchTermEN)
 		if err != nil {
 			return err
@@ -110,12 +329,49 @@ func (cmd *Subscribe) Handle(s *discordgo.Session, i *discordgo.InteractionCreat
 		}
 
 		subscription := &db.Subscription{
-			UserID:   UserID(i),
-			TermID:   term.ID,
-			MinPrice: minPrice,
-			MaxPrice: maxPrice,
+			UserID:          UserID(i),
+			TermID:          term.ID,
+			MinPrice:        minPrice,
+			MaxPrice:        maxPrice,
+			MinPollInterval: minInterval,
+			MaxPollInterval: maxInterval,
+			DealThreshold:   deal,
+		}
+
+		notifiers := []string{db.DefaultNotifier}
+		switch {
+		case channelID != nil:
+			notifiers = []string{db.ChannelNotifier}
+			subscription.GuildID = &i.GuildID
+			subscription.ChannelID = channelID
+		case discordHook != nil:
+			notifiers = []string{db.DiscordWebhookNotifier}
+			subscription.DiscordWebhookURL = discordHook
+		}
+
+		if webhook != nil {
+			notifiers = append(notifiers, db.WebhookNotifier)
+			subscription.WebhookURL = webhookURL
+			if webhookURL != nil {
+				// the bot's own webhook is signed with its secret, anyone else's gets one of their own
+				secret, err := randomToken()
+				if err != nil {
+					return err
+				}
+				subscription.WebhookSecret = &secret
+			}
+		}
+
+		if email {
+			notifiers = append(notifiers, db.EmailNotifier)
 		}
 
+		if pushPriority != nil {
+			notifiers = append(notifiers, db.PushNotifier)
+			subscription.PushPriority = pushPriority
+		}
+		subscription.Notifiers = notifiers
+
 		if err = cmd.db.CreateSubscription(subscription); err != nil {
 			if errors.Is(err, db.ErrConstraintUnique) {
 				return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
@@ -150,107 +406,471 @@ func (cmd *Subscribe) Handle(s *discordgo.Session, i *discordgo.InteractionCreat
 		})
 	case discordgo.InteractionMessageComponent:
 		_, args := FromCustomID(i.MessageComponentData().CustomID)
-		if len(args) != 2 {
+		if len(args) < 2 {
 			return nil
 		}
 
-		userID := UserID(i)
-		if userID == "" {
+		switch args[0] {
+		case "sub":
+			return cmd.handleShops(s, i, args[1])
+		case "preview":
+			page := 0
+			if len(args) > 2 {
+				page, _ = strconv.Atoi(args[2])
+			}
+			return cmd.handlePreview(s, i, args[1], page)
+		case "existing":
+			return cmd.handleExisting(s, i, args[1])
+		default:
 			return nil
 		}
+	default:
+		return nil
+	}
+}
 
-		subscription, err := cmd.db.GetSubscription(args[1])
-		if err != nil {
-			return err
-		}
+// checkTarget checks that the user may post new items in the channel or Discord webhook they picked. It returns what
+// the problem is if they may not.
+func (cmd *Subscribe) checkTarget(s *discordgo.Session, i *discordgo.InteractionCreate, channelID, discordHook *string) (string, error) {
+	if channelID == nil && discordHook == nil {
+		return "", nil
+	}
 
-		if subscription.UserID != userID {
-			return nil
-		}
+	if channelID != nil && discordHook != nil {
+		return "Pick either a channel or a Discord webhook, not both.", nil
+	}
 
-		term, err := cmd.db.GetTerm(subscription.TermID)
-		if err != nil {
-			return err
+	if channelID != nil {
+		if i.GuildID == "" || i.Member == nil {
+			return "Channel subscriptions can only be created in a server.", nil
 		}
 
-		for _, shop := range i.MessageComponentData().Values {
-			found, ok := sendico.ShopMap[shop]
-			if !ok {
-				continue
-			}
-			subscription.AddShop(found)
+		// the member isn't necessarily cached, but the interaction has everything needed to work out their permissions
+		member := *i.Member
+		member.GuildID = i.GuildID
+		if err := s.State.MemberAdd(&member); err != nil {
+			return "", err
 		}
 
-		if len(subscription.Shops()) == 0 {
-			return nil
+		if _, err := s.State.Channel(*channelID); err != nil {
+			return fmt.Sprintf("I can't see <#%s>, make sure I have access to it.", *channelID), nil
 		}
 
-		if err := cmd.db.UpdateSubscription(subscription); err != nil {
-			return err
+		permissions, err := s.State.UserChannelPermissions(member.User.ID, *channelID)
+		if err != nil {
+			slog.Warn("failed to check channel permissions", "err", err, "channel_id", *channelID, "user_id", member.User.ID)
+			return fmt.Sprintf("I couldn't check your permissions in <#%s>, try again in a bit.", *channelID), nil
 		}
 
-		err = cmd.seedCurrentItems(term, subscription)
-		if err != nil {
-			slog.Error("failed to seed current items", "err", err)
-			// this is best effort
+		if permissions&discordgo.PermissionManageChannels == 0 {
+			return fmt.Sprintf("You need the Manage Channels permission in <#%s>.", *channelID), nil
 		}
 
-		shopNames := make([]string, 0, len(subscription.Shops()))
-		for _, shop := range subscription.Shops() {
-			shopName := shop.Name()
+		return cmd.checkChannelRoles(i, i.GuildID)
+	}
 
-			if cmd.emojis.Has(shop.Identifier()) {
-				shopName = cmd.emojis.For(shop.Identifier()) + " " + shopName
-			}
+	id, token, ok := ParseDiscordWebhook(*discordHook)
+	if !ok {
+		return "Discord webhook must be a URL like `https://discord.com/api/webhooks/<id>/<token>`.", nil
+	}
+
+	webhook, err := s.WebhookWithToken(id, token)
+	if err != nil {
+		return "Couldn't find that Discord webhook, check the URL.", nil
+	}
 
-			shopNames = append(shopNames, shopName)
+	// creating a webhook takes Manage Webhooks, so having its URL is enough outside of this server
+	if i.Member != nil && webhook.GuildID == i.GuildID {
+		return cmd.checkChannelRoles(i, i.GuildID)
+	}
+
+	return "", nil
+}
+
+// checkChannelRoles checks that the member has one of the roles the guild lets create channel subscriptions.
+func (cmd *Subscribe) checkChannelRoles(i *discordgo.InteractionCreate, guildID string) (string, error) {
+	guild, err := cmd.db.GetGuild(guildID)
+	if err != nil {
+		return "", err
+	}
+
+	if !guild.AllowsChannelSubscriptions(i.Member.Roles) {
+		return "You don't have a role that may create channel subscriptions in this server, see `/channelroles list`.", nil
+	}
+
+	return "", nil
+}
+
+// checkEmail checks that the user has a verified address to email new items to. It returns what the problem is if they
+// don't.
+func (cmd *Subscribe) checkEmail(userID string) (string, error) {
+	if cmd.emails == nil {
+		return "Email is not enabled.", nil
+	}
+
+	email, err := cmd.db.GetEmail(userID)
+	if err != nil {
+		return "", err
+	}
+
+	if !email.IsVerified() {
+		return "You don't have a verified email address yet, set one with `/email set`.", nil
+	}
+
+	return "", nil
+}
+
+// checkPush checks that the user has a push server to push new items to. It returns what the problem is if they don't.
+func (cmd *Subscribe) checkPush(userID string) (string, error) {
+	if cmd.pushes == nil {
+		return "Push notifications are not enabled.", nil
+	}
+
+	push, err := cmd.db.GetPush(userID)
+	if err != nil {
+		return "", err
+	}
+
+	if !push.IsSet() {
+		return "You don't have a push server yet, set one with `/notify ntfy` or `/notify gotify`.", nil
+	}
+
+	return "", nil
+}
+
+// webhookURL validates the webhook option, it returns nil for the bot's default webhook.
+func (cmd *Subscribe) webhookURL(value string) (*string, error) {
+	if cmd.webhooks == nil {
+		return nil, errors.New("webhooks are not enabled")
+	}
+
+	if value == "default" {
+		if !cmd.webhooks.HasDefault {
+			return nil, errors.New("there is no default webhook, use a URL instead")
 		}
+		return nil, nil
+	}
 
-		msg := fmt.Sprintf("🔔 Subscribed for term: %q (%s)\nWill check shops: %s", term.EN, term.JP, strings.Join(shopNames, ", "))
-		if subscription.MinPrice != nil || subscription.MaxPrice != nil {
-			msg += "\n"
-			if subscription.MaxPrice == nil {
-				msg += fmt.Sprintf("Will only alert on items ¥%d or more", *subscription.MinPrice)
-			} else if subscription.MinPrice == nil {
-				msg += fmt.Sprintf("Will only alert on items ¥%d or less", *subscription.MaxPrice)
-			} else {
-				msg += fmt.Sprintf("Will only alert on items ¥%d - ¥%d", *subscription.MinPrice, *subscription.MaxPrice)
-			}
+	// the webhook notifier checks again when it connects, in case the host is pointed somewhere else later
+	if err := notify.CheckPublicURL(context.Background(), value); err != nil {
+		return nil, err
+	}
+
+	return &value, nil
+}
+
+// ownSubscription returns the subscription if it belongs to the user of the interaction, or nil if it doesn't.
+func (cmd *Subscribe) ownSubscription(i *discordgo.InteractionCreate, subID string) (*db.Subscription, error) {
+	userID := UserID(i)
+	if userID == "" {
+		return nil, nil
+	}
+
+	subscription, err := cmd.db.GetSubscription(subID)
+	if err != nil {
+		return nil, err
+	}
+
+	if subscription.UserID != userID {
+		return nil, nil
+	}
+
+	return subscription, nil
+}
+
+func (cmd *Subscribe) handleShops(s *discordgo.Session, i *discordgo.InteractionCreate, subID string) error {
+	subscription, err := cmd.ownSubscription(i, subID)
+	if err != nil || subscription == nil {
+		return err
+	}
+	userID := subscription.UserID
+
+	term, err := cmd.db.GetTerm(subscription.TermID)
+	if err != nil {
+		return err
+	}
+
+	for _, shop := range i.MessageComponentData().Values {
+		found, ok := sendico.ShopMap[shop]
+		if !ok {
+			continue
 		}
+		subscription.AddShop(found)
+	}
+
+	if len(subscription.Shops()) == 0 {
+		return nil
+	}
 
-		dm, err := s.UserChannelCreate(userID)
+	if err := cmd.db.UpdateSubscription(subscription); err != nil {
+		return err
+	}
+
+	if problem, err := cmd.startForumThread(s, term, subscription); err != nil || problem != "" {
 		if err != nil {
 			return err
 		}
 
-		_, err = s.ChannelMessageSend(dm.ID, msg)
-		if err != nil {
+		if err := cmd.db.DeleteUserSubscriptions(userID, subscription.ID); err != nil {
 			return err
 		}
 
 		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
-				Content: fmt.Sprintf("✅ Subscribed, <@%s>! You will receive a DM when new items are found.", userID),
+				Content: "⛔ " + problem,
+				Flags:   discordgo.MessageFlagsEphemeral,
 			},
 		})
-	default:
-		return nil
 	}
+
+	seeded, err := seedCurrentItems(cmd.db, cmd.sendico, term, subscription)
+	if err != nil {
+		slog.Error("failed to seed current items", "err", err)
+		// this is best effort
+	}
+
+	shopNames := make([]string, 0, len(subscription.Shops()))
+	for _, shop := range subscription.Shops() {
+		shopName := shop.Name()
+
+		if cmd.emojis.Has(shop.Identifier()) {
+			shopName = cmd.emojis.For(shop.Identifier()) + " " + shopName
+		}
+
+		shopNames = append(shopNames, shopName)
+	}
+
+	msg := fmt.Sprintf("🔔 Subscribed for term: %q (%s)\nWill check shops: %s", term.EN, term.JP, strings.Join(shopNames, ", "))
+	if subscription.MinPrice != nil || subscription.MaxPrice != nil {
+		msg += "\n"
+		if subscription.MaxPrice == nil {
+			msg += fmt.Sprintf("Will only alert on items ¥%d or more", *subscription.MinPrice)
+		} else if subscription.MinPrice == nil {
+			msg += fmt.Sprintf("Will only alert on items ¥%d or less", *subscription.MaxPrice)
+		} else {
+			msg += fmt.Sprintf("Will only alert on items ¥%d - ¥%d", *subscription.MinPrice, *subscription.MaxPrice)
+		}
+	}
+
+	if subscription.WebhookSecret != nil {
+		msg += fmt.Sprintf("\n🔑 Webhook requests are signed with this secret, it won't be shown again: ||`%s`||", *subscription.WebhookSecret)
+	}
+
+	dm, err := s.UserChannelCreate(userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.ChannelMessageSend(dm.ID, msg)
+	if err != nil {
+		return err
+	}
+
+	content := fmt.Sprintf("✅ Subscribed, <@%s>! You will receive a DM when new items are found.", userID)
+	switch {
+	case subscription.ThreadID != nil:
+		content = fmt.Sprintf("✅ Subscribed, <@%s>! New items will be posted in <#%s>.", userID, *subscription.ThreadID)
+	case subscription.ChannelID != nil:
+		content = fmt.Sprintf("✅ Subscribed, <@%s>! New items will be posted in <#%s>.", userID, *subscription.ChannelID)
+	case subscription.DiscordWebhookURL != nil:
+		content = fmt.Sprintf("✅ Subscribed, <@%s>! New items will be posted through your Discord webhook.", userID)
+	}
+	var components []discordgo.MessageComponent
+	if len(seeded) > 0 {
+		cmd.storePreview(subscription.ID, seeded)
+
+		content += fmt.Sprintf("\n👀 There are %d listing(s) up right now, they won't be sent to you unless you ask for them.", len(seeded))
+		components = []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "Show current listings",
+						Style:    discordgo.SecondaryButton,
+						CustomID: cmd.Name() + ":preview:" + subscription.ID + ":0",
+						Emoji:    &discordgo.ComponentEmoji{Name: "👀"},
+					},
+					discordgo.Button{
+						Label:    "Notify on existing listings too",
+						Style:    discordgo.PrimaryButton,
+						CustomID: cmd.Name() + ":existing:" + subscription.ID,
+						Emoji:    &discordgo.ComponentEmoji{Name: "📨"},
+					},
+				},
+			},
+		}
+	}
+
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Components: components,
+		},
+	})
+}
+
+// startForumThread starts a post for the subscription if it delivers to a forum channel, the post is where its items
+// are sent. It returns what the problem is if the post couldn't be started.
+func (cmd *Subscribe) startForumThread(s *discordgo.Session, term *db.Term, subscription *db.Subscription) (string, error) {
+	if subscription.ChannelID == nil || subscription.ThreadID != nil {
+		return "", nil
+	}
+
+	channel, err := s.State.Channel(*subscription.ChannelID)
+	if err != nil {
+		if channel, err = s.Channel(*subscription.ChannelID); err != nil {
+			return fmt.Sprintf("I can't see <#%s>, make sure I have access to it.", *subscription.ChannelID), nil
+		}
+	}
+
+	if channel.Type != discordgo.ChannelTypeGuildForum {
+		return "", nil
+	}
+
+	thread, err := startThread(s, channel, cmd.emojis, term, subscription)
+	if thread == nil {
+		slog.Error("failed to start forum thread", "err", err, "channel_id", channel.ID, "sub_id", subscription.ID)
+		return fmt.Sprintf("I couldn't create a post in <#%s>, make sure I can create posts and send messages in it.", channel.ID), nil
+	}
+	if err != nil {
+		slog.Error("failed to pin forum thread header", "err", err, "thread_id", thread.ID, "sub_id", subscription.ID)
+		// the thread works without it
+	}
+
+	subscription.ThreadID = &thread.ID
+	return "", cmd.db.UpdateSubscription(subscription)
 }
 
-func (cmd *Subscribe) seedCurrentItems(term *db.Term, sub *db.Subscription) error {
-	results, err := cmd.sendico.BulkSearch(context.Background(), sub.Shops(), sendico.SearchOptions{
-		TermJP:   term.JP,
-		MinPrice: sub.MinPrice,
-		MaxPrice: sub.MaxPrice,
+// handlePreview shows a page of the listings that were up when subscribing. The first page is sent as a new ephemeral
+// reply, paging through it edits that reply in place.
+func (cmd *Subscribe) handlePreview(s *discordgo.Session, i *discordgo.InteractionCreate, subID string, page int) error {
+	subscription, err := cmd.ownSubscription(i, subID)
+	if err != nil || subscription == nil {
+		return err
+	}
+
+	responseType := discordgo.InteractionResponseChannelMessageWithSource
+	if i.Message != nil && i.Message.Flags&discordgo.MessageFlagsEphemeral != 0 {
+		responseType = discordgo.InteractionResponseUpdateMessage
+	}
+
+	items := cmd.loadPreview(subscription.ID)
+	if len(items) == 0 {
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: responseType,
+			Data: &discordgo.InteractionResponseData{
+				Content: "⌛ This preview has expired, new listings will still be sent to you.",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+	}
+
+	pages := (len(items) + PreviewPageSize - 1) / PreviewPageSize
+	page = max(0, min(page, pages-1))
+	start := page * PreviewPageSize
+	end := min(start+PreviewPageSize, len(items))
+
+	embeds := make([]*discordgo.MessageEmbed, 0, end-start)
+	for _, item := range items[start:end] {
+		embeds = append(embeds, ItemEmbed(cmd.emojis, item))
+	}
+
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: responseType,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("👀 Current listings (%d/%d), %d total", page+1, pages, len(items)),
+			Embeds:  embeds,
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{
+							Label:    "Prev",
+							Style:    discordgo.SecondaryButton,
+							CustomID: cmd.Name() + ":preview:" + subscription.ID + ":" + strconv.Itoa(page-1),
+							Disabled: page == 0,
+						},
+						discordgo.Button{
+							Label:    "Next",
+							Style:    discordgo.SecondaryButton,
+							CustomID: cmd.Name() + ":preview:" + subscription.ID + ":" + strconv.Itoa(page+1),
+							Disabled: page == pages-1,
+						},
+					},
+				},
+			},
+		},
 	})
+}
+
+// handleExisting queues the listings that were up when subscribing, so they are sent like newly found items.
+func (cmd *Subscribe) handleExisting(s *discordgo.Session, i *discordgo.InteractionCreate, subID string) error {
+	subscription, err := cmd.ownSubscription(i, subID)
+	if err != nil || subscription == nil {
+		return err
+	}
+
+	respond := func(content string) error {
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+	}
+
+	items, ok := cmd.takePreview(subscription.ID)
+	if !ok {
+		return respond("⌛ These listings were already queued or have expired, new listings will still be sent to you.")
+	}
+
+	user, err := cmd.db.GetUser(subscription.UserID)
 	if err != nil {
 		return err
 	}
 
+	// delivered like the items the looper finds, so digests and quiet hours apply
+	deliverAt, digest := looper.DeliverAt(user, time.Now())
+	entries := make([]*db.OutboxEntry, 0, len(subscription.NotifierNames()))
+	for _, notifier := range subscription.NotifierNames() {
+		entries = append(entries, &db.OutboxEntry{
+			SubscriptionID: subscription.ID,
+			Items:          items,
+			NextAttemptAt:  deliverAt,
+			Digest:         digest,
+			Reason:         db.OutboxReasonNew,
+			Notifier:       notifier,
+		})
+	}
+
+	// the items were tracked when seeding, so there is nothing left to track
+	if err := cmd.db.EnqueueItems(entries); err != nil {
+		return err
+	}
+
+	if err := cmd.db.SaveMatches(subscription.ID, items...); err != nil {
+		// they are already queued, they just won't be in /history or the feed
+		slog.Error("failed to save matches", "err", err, "sub_id", subscription.ID)
+	}
+
+	if digest {
+		return respond(fmt.Sprintf("📨 Will send the %d current listing(s) in your next digest.", len(items)))
+	}
+	return respond(fmt.Sprintf("📨 Will send the %d current listing(s) shortly.", len(items)))
+}
+
+// seedCurrentItems marks the items that are currently listed as seen and sets the subscription's high-water mark, so
+// only items listed after subscribing are sent. It returns the items it found.
+func seedCurrentItems(store db.DB, client *sendico.Client, term *db.Term, sub *db.Subscription) ([]sendico.Item, error) {
+	results, err := client.BulkSearch(context.Background(), sub.Shops(), sub.SearchOptions(*term))
+	if err != nil {
+		return nil, err
+	}
+
 	if len(results) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	items := make([]db.Item, 0, len(results))
@@ -262,7 +882,65 @@ func (cmd *Subscribe) seedCurrentItems(term *db.Term, sub *db.Subscription) erro
 		})
 	}
 
-	return cmd.db.TrackItems(items...)
+	if err := store.TrackItems(items...); err != nil {
+		return nil, err
+	}
+
+	firstSeen, err := store.GetFirstSeen(results...)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, mark := looper.ListedSince(results, nil, firstSeen); mark != nil {
+		sub.HighWaterMark = mark
+		if err := store.UpdateSubscription(sub); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+func (cmd *Subscribe) storePreview(subID string, items []sendico.Item) {
+	cmd.mu.Lock()
+	defer cmd.mu.Unlock()
+
+	now := time.Now()
+	for id, p := range cmd.previews {
+		if now.After(p.expires) {
+			delete(cmd.previews, id)
+		}
+	}
+
+	cmd.previews[subID] = preview{items: items, expires: now.Add(PreviewTTL)}
+}
+
+// loadPreview returns the stored listings of a subscription, or nil if they have expired.
+func (cmd *Subscribe) loadPreview(subID string) []sendico.Item {
+	cmd.mu.Lock()
+	defer cmd.mu.Unlock()
+
+	p, ok := cmd.previews[subID]
+	if !ok || time.Now().After(p.expires) {
+		return nil
+	}
+
+	return p.items
+}
+
+// takePreview returns the stored listings of a subscription once, so they can't be queued twice.
+func (cmd *Subscribe) takePreview(subID string) ([]sendico.Item, bool) {
+	cmd.mu.Lock()
+	defer cmd.mu.Unlock()
+
+	p, ok := cmd.previews[subID]
+	if !ok || p.queued || time.Now().After(p.expires) {
+		return nil, false
+	}
+
+	p.queued = true
+	cmd.previews[subID] = p
+	return p.items, true
 }
 
 func (cmd *Subscribe) options() []discordgo.SelectMenuOption {
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"slices"
 	"strconv"
 	"strings"
 
@@ -27,12 +28,20 @@ func (cmd *Subscriptions) Description() string {
 }
 
 func (cmd *Subscriptions) Handle(s *discordgo.Session, i *discordgo.InteractionCreate) error {
-	if i.Type != discordgo.InteractionApplicationCommand {
+	userID := UserID(i)
+	if userID == "" {
 		return nil
 	}
 
-	userID := UserID(i)
-	if userID == "" {
+	if i.Type == discordgo.InteractionMessageComponent {
+		_, args := FromCustomID(i.MessageComponentData().CustomID)
+		if len(args) > 0 && args[0] == "vote" {
+			return cmd.handleVote(s, i, userID, args)
+		}
+		return nil
+	}
+
+	if i.Type != discordgo.InteractionApplicationCommand {
 		return nil
 	}
 
@@ -51,9 +60,13 @@ func (cmd *Subscriptions) Handle(s *discordgo.Session, i *discordgo.InteractionC
 		for _, sub := range subs {
 			builder.WriteString("- \"")
 			builder.WriteString(sub.Term.EN)
-			builder.WriteString("\" (")
-			builder.WriteString(sub.Term.JP)
-			builder.WriteString(") ")
+			builder.WriteString("\" ")
+			// seller watches have no term, they search for everything the seller lists
+			if sub.Term.JP != "" {
+				builder.WriteString("(")
+				builder.WriteString(sub.Term.JP)
+				builder.WriteString(") ")
+			}
 
 			if sub.Subscription.MinPrice != nil || sub.Subscription.MaxPrice != nil {
 				builder.WriteString("¥")
@@ -71,6 +84,12 @@ func (cmd *Subscriptions) Handle(s *discordgo.Session, i *discordgo.InteractionC
 				builder.WriteString(" ")
 			}
 
+			if sub.Subscription.DealThreshold != nil {
+				builder.WriteString("🔥 ")
+				builder.WriteString(strconv.Itoa(*sub.Subscription.DealThreshold))
+				builder.WriteString("% below usual ")
+			}
+
 			for i, shop := range sub.Subscription.Shops() {
 				if cmd.emojis.Has(shop.Identifier()) {
 					builder.WriteString(cmd.emojis.For(shop.Identifier()))
@@ -81,7 +100,68 @@ func (cmd *Subscriptions) Handle(s *discordgo.Session, i *discordgo.InteractionC
 					}
 				}
 			}
+
+			switch {
+			case sub.Subscription.ThreadID != nil:
+				builder.WriteString(" 📢 <#")
+				builder.WriteString(*sub.Subscription.ThreadID)
+				builder.WriteString(">")
+			case sub.Subscription.ChannelID != nil:
+				builder.WriteString(" 📢 <#")
+				builder.WriteString(*sub.Subscription.ChannelID)
+				builder.WriteString(">")
+			case sub.Subscription.DiscordWebhookURL != nil:
+				builder.WriteString(" 📢 Discord webhook")
+			}
+
+			if slices.Contains(sub.Subscription.NotifierNames(), db.WebhookNotifier) {
+				builder.WriteString(" 🪝 webhook")
+			}
+
+			if slices.Contains(sub.Subscription.NotifierNames(), db.EmailNotifier) {
+				builder.WriteString(" 📧 email")
+			}
+
+			if slices.Contains(sub.Subscription.NotifierNames(), db.PushNotifier) {
+				builder.WriteString(" 📲 push")
+			}
+
+			if sub.Subscription.IsPaused() {
+				builder.WriteString(" ⏸️ paused")
+				if sub.Subscription.PauseReason != nil {
+					switch *sub.Subscription.PauseReason {
+					case db.PauseReasonUnreachable:
+						builder.WriteString(" (I couldn't DM you, resumed now)")
+					case db.PauseReasonUser:
+						builder.WriteString(" (use `/resume` to pick it back up)")
+					case db.PauseReasonTargetGone:
+						builder.WriteString(" (I lost access to where it posts to, use `/resume` once that's fixed)")
+					case db.PauseReasonNotifierDisabled:
+						builder.WriteString(" (one of its notifications is turned off on this bot, use `/resume` once it's back)")
+					case db.PauseReasonRejected:
+						builder.WriteString(" (where it posts to refused its notifications, fix it and use `/resume`)")
+					case db.PauseReasonUndeliverable:
+						builder.WriteString(" (its notifications kept failing to send, check where it posts to and use `/resume`)")
+					}
+				}
+				builder.WriteString("\n")
+				continue
+			}
+
+			builder.WriteString(" ⏱️ every ")
+			builder.WriteString(FormatInterval(sub.Subscription.PollInterval))
+			if sub.Subscription.PollReason != "" {
+				builder.WriteString(" (")
+				builder.WriteString(sub.Subscription.PollReason)
+				builder.WriteString(")")
+			}
 			builder.WriteString("\n")
+
+			suggestion, err := cmd.suggestKeywords(sub.Subscription.ID)
+			if err != nil {
+				return err
+			}
+			builder.WriteString(suggestion)
 		}
 	}
 
@@ -33,49 +33,15 @@ func (cmd *Unsubscribe) Handle(s *discordgo.Session, i *discordgo.InteractionCre
 			return nil
 		}
 
-		subs, err := cmd.db.GetUserSubscriptions(userID)
-		if err != nil {
-			return err
-		}
-
-		if len(subs) == 0 {
-			return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: "ℹ️ You have no subscriptions to unsubscribe from.",
-				},
-			})
-		}
-
-		options := make([]discordgo.SelectMenuOption, 0, len(subs))
-		for _, sub := range subs {
-			options = append(options, discordgo.SelectMenuOption{
-				Label: sub.Term.EN,
-				Value: sub.Subscription.ID,
-			})
+		return cmd.handleMenu(s, i, userID, 0)
+	case discordgo.InteractionMessageComponent:
+		userID := UserID(i)
+		_, args := FromCustomID(i.MessageComponentData().CustomID)
+		if page, ok := menuPage(args); ok {
+			return cmd.handleMenu(s, i, userID, page)
 		}
 
-		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				CustomID: cmd.Name(),
-				Components: []discordgo.MessageComponent{
-					discordgo.ActionsRow{
-						Components: []discordgo.MessageComponent{
-							discordgo.SelectMenu{
-								CustomID:    cmd.Name() + ":remove",
-								Placeholder: "⏹️ What subscription(s) would you like to remove?",
-								Options:     options,
-								MaxValues:   len(options),
-							},
-						},
-					},
-				},
-			},
-		})
-	case discordgo.InteractionMessageComponent:
 		subIDs := i.MessageComponentData().Values
-		userID := UserID(i)
 
 		subscriptions, err := cmd.db.GetUserSubscriptions(userID)
 		if err != nil {
@@ -126,3 +92,47 @@ func (cmd *Unsubscribe) Handle(s *discordgo.Session, i *discordgo.InteractionCre
 		return nil
 	}
 }
+
+// handleMenu shows a page of the menu of subscriptions to remove. The first page is sent as a new reply, paging through
+// it edits that reply in place.
+func (cmd *Unsubscribe) handleMenu(s *discordgo.Session, i *discordgo.InteractionCreate, userID string, page int) error {
+	responseType := discordgo.InteractionResponseChannelMessageWithSource
+	if i.Type == discordgo.InteractionMessageComponent {
+		responseType = discordgo.InteractionResponseUpdateMessage
+	}
+
+	subs, err := cmd.db.GetUserSubscriptions(userID)
+	if err != nil {
+		return err
+	}
+
+	if len(subs) == 0 {
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: responseType,
+			Data: &discordgo.InteractionResponseData{
+				Content:    "ℹ️ You have no subscriptions to unsubscribe from.",
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+	}
+
+	options := make([]discordgo.SelectMenuOption, 0, len(subs))
+	for _, sub := range subs {
+		options = append(options, discordgo.SelectMenuOption{
+			Label: sub.Term.EN,
+			Value: sub.Subscription.ID,
+		})
+	}
+
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: responseType,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: cmd.Name(),
+			Components: pagedSelectMenu(discordgo.SelectMenu{
+				CustomID:    cmd.Name() + ":remove",
+				Placeholder: "⏹️ What subscription(s) would you like to remove?",
+				MaxValues:   len(options),
+			}, options, cmd.Name()+":page", page),
+		},
+	})
+}
@@ -0,0 +1,157 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robherley/sendibot/internal/bot/cmd"
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/pkg/sendico"
+)
+
+const (
+	// maxEmbedDescription is a bit under discord's limit of 4096 characters for an embed's description.
+	maxEmbedDescription = 4000
+	// maxMessageEmbedChars is a bit under discord's limit of 6000 characters across all embeds of a message.
+	maxMessageEmbedChars = 5500
+)
+
+func (b *Bot) digestMessages(batch []TermItems) []message {
+	total := 0
+	groups := groupMatches(batch)
+	embeds := make([]*discordgo.MessageEmbed, 0)
+	shown := make([][]sendico.Item, 0)
+	for _, group := range groups {
+		total += len(group.Items)
+		groupEmbeds, groupShown := b.digestEmbeds(group)
+		embeds = append(embeds, groupEmbeds...)
+		shown = append(shown, groupShown...)
+	}
+
+	packed := packEmbeds(embeds)
+	messages := make([]message, 0, len(packed))
+	for i, items := range packedItems(packed, shown) {
+		msg := &discordgo.MessageSend{Embeds: packed[i]}
+		if i == 0 {
+			msg.Content = fmt.Sprintf("📬 Your digest: %d new item(s)", total)
+		}
+		messages = append(messages, message{MessageSend: msg, items: items})
+	}
+
+	return messages
+}
+
+// digestEmbeds renders a group as one or more embeds, continuing on a new embed when the description is full. It also
+// returns the items each embed shows.
+func (b *Bot) digestEmbeds(group matchGroup) ([]*discordgo.MessageEmbed, [][]sendico.Item) {
+	var (
+		embeds      []*discordgo.MessageEmbed
+		shown       [][]sendico.Item
+		items       []sendico.Item
+		description strings.Builder
+	)
+
+	flush := func() {
+		title := "🔔 " + quoteTerms(group.Terms)
+		if len(embeds) > 0 {
+			title += " (continued)"
+		}
+		embeds = append(embeds, &discordgo.MessageEmbed{
+			Title:       title,
+			Description: description.String(),
+		})
+		shown = append(shown, items)
+		description.Reset()
+		items = nil
+	}
+
+	for _, item := range group.Items {
+		line := b.digestLine(item, group.Notes)
+		if description.Len()+len(line) > maxEmbedDescription {
+			flush()
+		}
+		description.WriteString(line)
+		items = append(items, item)
+	}
+
+	if description.Len() > 0 {
+		flush()
+	}
+
+	return embeds, shown
+}
+
+// digestLine renders an item as a line of a digest, with what its note says.
+func (b *Bot) digestLine(item sendico.Item, notes map[string]db.ItemNote) string {
+	shop := item.Shop.Name()
+	if b.emojis.Has(item.Shop.Identifier()) {
+		shop = b.emojis.For(item.Shop.Identifier())
+	}
+
+	name := strings.NewReplacer("[", "(", "]", ")").Replace(item.Name)
+	line := fmt.Sprintf("- %s [%s](%s) ¥%d ($%d)", shop, name, item.SendicoLink(), item.PriceYen, item.PriceUSD)
+	note := notes[db.NoteKey(item)]
+	if note.Deal != nil {
+		line += " · " + cmd.DealLabel(*note.Deal)
+	}
+	if len(note.Variants) > 0 {
+		line += fmt.Sprintf(" · also listed %d more time(s)", len(note.Variants))
+	}
+	if note.Followed {
+		line += " · " + cmd.FollowedLabel(item)
+	}
+	if note.Irrelevance > 0 {
+		line += " · " + cmd.RelevanceLabel(note.Irrelevance)
+	}
+	return line + "\n"
+}
+
+// embedSize is the number of characters of an embed that count towards discord's message limit.
+func embedSize(embed *discordgo.MessageEmbed) int {
+	size := len(embed.Title) + len(embed.Description)
+	for _, field := range embed.Fields {
+		size += len(field.Name) + len(field.Value)
+	}
+	return size
+}
+
+// packEmbeds splits embeds into messages that fit discord's limits.
+func packEmbeds(embeds []*discordgo.MessageEmbed) [][]*discordgo.MessageEmbed {
+	var (
+		messages [][]*discordgo.MessageEmbed
+		current  []*discordgo.MessageEmbed
+		size     int
+	)
+
+	for _, embed := range embeds {
+		embedSize := embedSize(embed)
+		if len(current) == MaxEmbedsPerMessage || (len(current) > 0 && size+embedSize > maxMessageEmbedChars) {
+			messages = append(messages, current)
+			current, size = nil, 0
+		}
+		current = append(current, embed)
+		size += embedSize
+	}
+
+	if len(current) > 0 {
+		messages = append(messages, current)
+	}
+
+	return messages
+}
+
+// packedItems joins the items shown by each embed into the items shown by each message the embeds were packed into.
+func packedItems(packed [][]*discordgo.MessageEmbed, shown [][]sendico.Item) [][]sendico.Item {
+	items := make([][]sendico.Item, 0, len(packed))
+	offset := 0
+	for _, embeds := range packed {
+		var message []sendico.Item
+		for _, embedItems := range shown[offset : offset+len(embeds)] {
+			message = append(message, embedItems...)
+		}
+		items = append(items, message)
+		offset += len(embeds)
+	}
+	return items
+}
@@ -0,0 +1,34 @@
+package bot
+
+import (
+	"errors"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robherley/sendibot/internal/notify"
+)
+
+// classifyDeliveryError wraps Discord errors that will never succeed on retry with notify.ErrUnreachable, e.g. the user
+// blocked the bot, closed their DMs or no longer shares a guild with it. Everything else,
+// like server errors and rate limits, is left as is and considered transient.
+func classifyDeliveryError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) || restErr.Message == nil {
+		return err
+	}
+
+	switch restErr.Message.Code {
+	case discordgo.ErrCodeCannotSendMessagesToThisUser,
+		discordgo.ErrCodeUnknownUser,
+		discordgo.ErrCodeUnknownChannel,
+		discordgo.ErrCodeMissingAccess,
+		discordgo.ErrCodeUnknownWebhook,
+		discordgo.ErrCodeInvalidWebhookTokenProvided:
+		return notify.NewUnreachableError(err)
+	default:
+		return err
+	}
+}
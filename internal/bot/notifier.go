@@ -0,0 +1,57 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/notify"
+)
+
+// NotifierName is the name the bot is registered under as a notifier, it delivers to the subscriber's DMs.
+const NotifierName = db.DefaultNotifier
+
+var _ notify.Notifier = (*Bot)(nil)
+
+func (b *Bot) Name() string {
+	return NotifierName
+}
+
+// Notify sends events to the DMs of the user they belong to, as a notification, digest or catch-up summary depending on
+// their reason.
+func (b *Bot) Notify(ctx context.Context, events ...notify.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	messages, err := b.messages(events)
+	if err != nil {
+		return err
+	}
+
+	sent, err := b.sendDM(events[0].Subscription.UserID, messages)
+	if err != nil {
+		return notify.NewPartialError(sentEvents(events, messages[:sent]), classifyDeliveryError(err))
+	}
+
+	return nil
+}
+
+// messages renders events that share a reason.
+func (b *Bot) messages(events []notify.Event) ([]message, error) {
+	batch := make([]TermItems, 0, len(events))
+	for _, event := range events {
+		batch = append(batch, TermItems{TermEN: event.Term.EN, Items: event.Items, Notes: event.Notes})
+	}
+
+	switch reason := events[0].Reason; reason {
+	case notify.ReasonNew:
+		return b.newItemsMessages(batch), nil
+	case notify.ReasonDigest:
+		return b.digestMessages(batch), nil
+	case notify.ReasonCatchUp:
+		return b.catchUpMessages(batch), nil
+	default:
+		return nil, fmt.Errorf("unsupported reason: %q", reason)
+	}
+}
@@ -0,0 +1,151 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robherley/sendibot/internal/bot/cmd"
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/notify"
+)
+
+// ChannelNotifier posts events in the guild channels their subscriptions deliver to, or in their own posts in forum
+// channels.
+type ChannelNotifier struct {
+	bot *Bot
+}
+
+var _ notify.Notifier = (*ChannelNotifier)(nil)
+
+// Channels returns a notifier that posts in guild channels as the bot.
+func (b *Bot) Channels() *ChannelNotifier {
+	return &ChannelNotifier{b}
+}
+
+func (n *ChannelNotifier) Name() string {
+	return db.ChannelNotifier
+}
+
+func (n *ChannelNotifier) Notify(ctx context.Context, events ...notify.Event) error {
+	return notifyTargets(events, func(event notify.Event) *string {
+		if event.Subscription.ThreadID != nil {
+			return event.Subscription.ThreadID
+		}
+		return event.Subscription.ChannelID
+	}, func(channelID string, messages []message) (int, error) {
+		return n.bot.sendChannel(channelID, messages)
+	}, n.bot.messages)
+}
+
+// DiscordWebhookNotifier posts events through the Discord webhooks their subscriptions deliver to, the bot doesn't need
+// to be a member of the webhook's guild.
+type DiscordWebhookNotifier struct {
+	bot *Bot
+}
+
+var _ notify.Notifier = (*DiscordWebhookNotifier)(nil)
+
+// DiscordWebhooks returns a notifier that executes Discord webhooks.
+func (b *Bot) DiscordWebhooks() *DiscordWebhookNotifier {
+	return &DiscordWebhookNotifier{b}
+}
+
+func (n *DiscordWebhookNotifier) Name() string {
+	return db.DiscordWebhookNotifier
+}
+
+func (n *DiscordWebhookNotifier) Notify(ctx context.Context, events ...notify.Event) error {
+	return notifyTargets(events, func(event notify.Event) *string {
+		return event.Subscription.DiscordWebhookURL
+	}, func(webhookURL string, messages []message) (int, error) {
+		id, token, ok := cmd.ParseDiscordWebhook(webhookURL)
+		if !ok {
+			return 0, notify.NewUnreachableError(errors.New("invalid discord webhook url"))
+		}
+
+		return sendMessages(messages, func(msg *discordgo.MessageSend) error {
+			_, err := n.bot.session.WebhookExecute(id, token, true, &discordgo.WebhookParams{
+				Content:  msg.Content,
+				Embeds:   msg.Embeds,
+				Username: "sendibot",
+			})
+			return err
+		})
+	}, n.bot.messages)
+}
+
+// notifyTargets groups events by the destination of their subscriptions, then renders and sends each group. A group
+// that fails, or an event without a destination, doesn't stop the others: the events that went out, including the ones
+// of a group that failed partway, are reported in a notify.PartialError along with why each of the others failed.
+func notifyTargets(
+	events []notify.Event,
+	target func(notify.Event) *string,
+	send func(target string, messages []message) (int, error),
+	render func([]notify.Event) ([]message, error),
+) error {
+	var (
+		targets []string
+		sent    []int
+		failed  = make(map[int]error)
+		groups  = make(map[string][]int)
+	)
+	for i, event := range events {
+		t := target(event)
+		if t == nil || *t == "" {
+			failed[i] = notify.NewUnreachableError(errors.New("subscription has no destination"))
+			continue
+		}
+
+		if _, ok := groups[*t]; !ok {
+			targets = append(targets, *t)
+		}
+		groups[*t] = append(groups[*t], i)
+	}
+
+	for _, t := range targets {
+		group := make([]notify.Event, 0, len(groups[t]))
+		for _, i := range groups[t] {
+			group = append(group, events[i])
+		}
+
+		messages, err := render(group)
+		if err != nil {
+			for _, i := range groups[t] {
+				failed[i] = err
+			}
+			continue
+		}
+
+		n, err := send(t, messages)
+		if err == nil {
+			sent = append(sent, groups[t]...)
+			continue
+		}
+
+		err = classifyDeliveryError(err)
+		groupSent := sentEvents(group, messages[:n])
+		for j, i := range groups[t] {
+			if slices.Contains(groupSent, j) {
+				sent = append(sent, i)
+			} else {
+				failed[i] = err
+			}
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	var firstErr error
+	for i := range events {
+		if err, ok := failed[i]; ok {
+			firstErr = err
+			break
+		}
+	}
+
+	return &notify.PartialError{Sent: sent, Err: firstErr, Failed: failed}
+}
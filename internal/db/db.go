@@ -3,6 +3,9 @@ package db
 import (
 	"context"
 	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
 	"time"
 
 	"github.com/robherley/sendibot/pkg/sendico"
@@ -10,8 +13,28 @@ import (
 
 var (
 	ErrConstraintUnique = errors.New("failed unique constraint")
+	ErrNotFound         = errors.New("not found")
 )
 
+const (
+	// WebhookNotifier is the name of the notifier that posts items to a webhook.
+	WebhookNotifier = "webhook"
+	// ChannelNotifier is the name of the notifier that posts items in a guild channel.
+	ChannelNotifier = "channel"
+	// DiscordWebhookNotifier is the name of the notifier that posts items through a Discord webhook.
+	DiscordWebhookNotifier = "discord_webhook"
+	// EmailNotifier is the name of the notifier that emails items to the user's verified address.
+	EmailNotifier = "email"
+	// PushNotifier is the name of the notifier that publishes items to the user's ntfy or Gotify server.
+	PushNotifier = "push"
+)
+
+// DefaultNotifier is the notifier subscriptions deliver through unless they are routed elsewhere, the Discord bot.
+const DefaultNotifier = "discord"
+
+// DefaultPollInterval is the poll interval of a subscription that has no history yet.
+const DefaultPollInterval = 10 * time.Minute
+
 type DB interface {
 	Close() error
 	Migrate(context.Context) error
@@ -19,14 +42,56 @@ type DB interface {
 	GetSubscription(id string) (*Subscription, error)
 	UpdateSubscription(*Subscription) error
 	GetUserSubscriptions(userID string) ([]TermSubscription, error)
-	FindSubscriptionsToNotify(window time.Duration, limit int) ([]TermSubscription, error)
-	SetNotified(subIDs ...string) error
+	FindSubscriptionsToNotify(limit int) ([]TermSubscription, error)
+	UpdateSchedule(*Subscription) error
+	GetPollLoad() (float64, error)
 	DeleteUserSubscriptions(userID string, ids ...string) error
 	CreateTerm(*Term) error
 	GetTerm(id string) (*Term, error)
 	FilterBySeenItems(items []Item) ([]Item, error)
 	TrackItems(items ...Item) error
 	CleanupItems(window time.Duration) error
+	EnqueueItems(entries []*OutboxEntry, items ...Item) error
+	FindPendingOutbox(limit int) ([]OutboxEntry, error)
+	MarkOutboxSent(id string) error
+	MarkOutboxFailed(id string, reason string, next time.Time) error
+	CleanupOutbox(sent, unsent time.Duration) error
+	RecordDeliveryFailure(userID string) (int, error)
+	ResetDeliveryFailures(userID string) error
+	PauseUserSubscriptions(userID string, reason PauseReason) error
+	PauseSubscription(id string, reason PauseReason) error
+	ResumeSubscription(id string) error
+	ResumeUserSubscriptions(userID string, reason PauseReason) (int, error)
+	GetUser(id string) (*User, error)
+	UpdateUserSettings(*User) error
+	GetGuild(id string) (*Guild, error)
+	UpdateGuild(*Guild) error
+	GetEmail(userID string) (*Email, error)
+	UpdateEmail(*Email) error
+	DeleteEmail(userID string) error
+	GetPush(userID string) (*Push, error)
+	UpdatePush(*Push) error
+	DeletePush(userID string) error
+	GetSubscriptionByFeedToken(token string) (*TermSubscription, error)
+	SaveMatches(subscriptionID string, items ...sendico.Item) error
+	GetMatches(subscriptionID string, limit int) ([]Match, error)
+	CleanupMatches(window time.Duration) error
+	SearchMatches(query MatchQuery) ([]SubscriptionMatch, int, error)
+	SaveSnapshots(termID string, items ...sendico.Item) error
+	GetSnapshot(shop sendico.Shop, code string) (*Snapshot, error)
+	GetPriceHistory(shop sendico.Shop, code string) ([]PricePoint, error)
+	CleanupSnapshots(window time.Duration) error
+	GetTermPrices(termID string, since time.Time) (map[sendico.Shop][]int, error)
+	GetFirstSeen(items ...sendico.Item) (map[string]time.Time, error)
+	GetImageHashes(items ...sendico.Item) (map[string]uint64, error)
+	SaveImageHash(item sendico.Item, hash uint64) error
+	SaveFeedback(userID string, shop sendico.Shop, code string, vote Vote) (int, error)
+	GetFeedback(subscriptionID string) ([]Feedback, error)
+	SaveSeller(userID string, seller Seller) error
+	GetSellers(userID string) ([]Seller, error)
+	DeleteSeller(userID string, shop sendico.Shop, sellerID string) error
+	CountSent(userID string, count int) error
+	SplitOutbox(id string, keep []sendico.Item, spill *OutboxEntry) error
 }
 
 type Term struct {
@@ -43,8 +108,92 @@ type Subscription struct {
 	ShopsBitField  int
 	MinPrice       *int
 	MaxPrice       *int
+
+	// PollInterval is how long to wait between searches, PollReason explains why it was chosen.
+	PollInterval time.Duration
+	PollReason   string
+	// MinPollInterval and MaxPollInterval are the user's floor and ceiling for PollInterval.
+	MinPollInterval *time.Duration
+	MaxPollInterval *time.Duration
+	// NextPollAt is when the subscription is next due to be searched, nil means right away.
+	NextPollAt *time.Time
+	// NewItemRate is the smoothed number of new items seen per hour.
+	NewItemRate float64
+	// Notifiers are the names of the notifiers the subscription's items are delivered through.
+	Notifiers []string
+	// WebhookURL is where the webhook notifier posts the subscription's items, nil for the globally configured URL.
+	WebhookURL *string
+	// WebhookSecret signs the requests to WebhookURL, it is only shown to the user when they subscribe.
+	WebhookSecret *string
+	// GuildID and ChannelID are the guild channel the channel notifier posts the subscription's items in.
+	GuildID   *string
+	ChannelID *string
+	// ThreadID is the forum thread the channel notifier posts in instead, when ChannelID is a forum channel.
+	ThreadID *string
+	// PushPriority is the priority the push notifier publishes the subscription's items with, from 1 (min) to 5 (max).
+	PushPriority *int
+	// FeedToken is the secret in the URL of the subscription's Atom feed, nil until the user asks for it.
+	FeedToken *string
+	// DiscordWebhookURL is the Discord webhook the discord_webhook notifier executes with the subscription's items.
+	DiscordWebhookURL *string
+	// DealThreshold is how far below the usual price, in percent, items have to be to be alerted on. Nil alerts on
+	// every item.
+	DealThreshold *int
+	// SellerID makes the subscription a seller watch, which only searches the items of that seller on its one shop.
+	SellerID *string
+
+	// HighWaterMark is the listing time of the newest item seen, items listed before it are not new. It is nil until a
+	// search returns an item with a listing time.
+	HighWaterMark *time.Time
+
+	// PausedAt is when the subscription stopped being searched, nil if it is active.
+	PausedAt    *time.Time
+	PauseReason *PauseReason
+}
+
+func (s *Subscription) IsPaused() bool {
+	return s.PausedAt != nil
 }
 
+// SearchOptions returns what the subscription searches its shops for, newest items first.
+func (s *Subscription) SearchOptions(term Term) sendico.SearchOptions {
+	opts := sendico.SearchOptions{
+		TermJP:   term.JP,
+		MinPrice: s.MinPrice,
+		MaxPrice: s.MaxPrice,
+		Sort:     sendico.SortNewest,
+	}
+	if s.SellerID != nil {
+		opts.Seller = *s.SellerID
+	}
+	return opts
+}
+
+// NotifierNames returns the notifiers to deliver through, the subscriber's DMs if none are set.
+func (s *Subscription) NotifierNames() []string {
+	if len(s.Notifiers) == 0 {
+		return []string{DefaultNotifier}
+	}
+	return s.Notifiers
+}
+
+type PauseReason string
+
+const (
+	// PauseReasonUnreachable is used when the user could not be messaged repeatedly.
+	PauseReasonUnreachable PauseReason = "unreachable"
+	// PauseReasonTargetGone is used when the channel or webhook a subscription posts to no longer exists.
+	PauseReasonTargetGone PauseReason = "target_gone"
+	// PauseReasonNotifierDisabled is used when a subscription sends to a notifier that isn't configured on the bot.
+	PauseReasonNotifierDisabled PauseReason = "notifier_disabled"
+	// PauseReasonUndeliverable is used when deliveries for a subscription kept failing.
+	PauseReasonUndeliverable PauseReason = "undeliverable"
+	// PauseReasonRejected is used when where a subscription posts to refused its notifications.
+	PauseReasonRejected PauseReason = "rejected"
+	// PauseReasonUser is used when the user paused the subscription themselves.
+	PauseReasonUser PauseReason = "user"
+)
+
 func (s *Subscription) AddShop(shop sendico.Shop) {
 	s.ShopsBitField |= int(shop)
 }
@@ -64,3 +213,273 @@ type Item struct {
 	Code           string
 	SubscriptionID string
 }
+
+// Key is a compact hash of the item's shop and code, it is what is kept of an item once it leaves the recent window.
+func (i Item) Key() int64 {
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%d:%s", i.Shop, i.Code)
+	return int64(h.Sum64())
+}
+
+// OutboxEntry is a batch of new items for a subscription that is waiting to be delivered.
+type OutboxEntry struct {
+	ID             string
+	SubscriptionID string
+	Items          []sendico.Item
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastError      *string
+	CreatedAt      time.Time
+	SentAt         *time.Time
+	// Digest entries are held until NextAttemptAt and delivered together as a digest.
+	Digest bool
+	Reason OutboxReason
+	// Notifier is the name of the notifier the entry is delivered through.
+	Notifier string
+	// Notes are what was worked out about the items when they were queued, by NoteKey.
+	Notes map[string]ItemNote
+
+	// Term and Subscription are populated when reading pending entries.
+	Term         Term
+	Subscription Subscription
+}
+
+// ItemNote is what was worked out about an item when it was queued, shown along with it.
+type ItemNote struct {
+	// Deal is the item's deal score, for subscriptions that only want deals.
+	Deal *Deal `json:"deal,omitempty"`
+	// Variants are duplicates of the item, on other shops or relisted, that were collapsed into it.
+	Variants []sendico.Item `json:"variants,omitempty"`
+	// Followed is set if the item is from a seller the user follows.
+	Followed bool `json:"followed,omitempty"`
+	// Irrelevance is how likely the item is to be voted down, in percent, going by the subscription's past votes. It is
+	// only set for items that are likely irrelevant.
+	Irrelevance int `json:"irrelevance,omitempty"`
+}
+
+// Deal is how an item's price compares to the usual price.
+type Deal struct {
+	// Score is how far below MedianYen the item's price is, in percent. It is nil if there weren't enough prices seen
+	// yet to tell.
+	Score     *int `json:"score,omitempty"`
+	MedianYen int  `json:"median_yen,omitempty"`
+}
+
+// Vote is a user's feedback on an item they were notified of.
+type Vote int
+
+const (
+	VoteDown Vote = -1
+	VoteUp   Vote = 1
+)
+
+// Feedback is a vote on an item a subscription matched.
+type Feedback struct {
+	Name    string
+	Vote    Vote
+	VotedAt time.Time
+}
+
+// SellerStatus is whether a user follows or blocks a seller.
+type SellerStatus string
+
+const (
+	// SellerFollowed sellers have all their new items sent, even if they wouldn't pass a subscription's other filters.
+	SellerFollowed SellerStatus = "follow"
+	// SellerBlocked sellers have none of their items sent.
+	SellerBlocked SellerStatus = "block"
+)
+
+// Seller is a seller a user follows or blocks, across all their subscriptions.
+type Seller struct {
+	Shop      sendico.Shop
+	ID        string
+	Status    SellerStatus
+	CreatedAt time.Time
+}
+
+// Key is the seller's key in the same form as NoteKey, "<shop identifier>:<seller ID>".
+func (s Seller) Key() string {
+	return s.Shop.Identifier() + ":" + s.ID
+}
+
+// NoteKey is the key of an item's note.
+func NoteKey(item sendico.Item) string {
+	return fmt.Sprintf("%s:%s", item.Shop.Identifier(), item.Code)
+}
+
+// OutboxReason is why items were queued, which changes how they are presented.
+type OutboxReason string
+
+const (
+	// OutboxReasonNew is used for items that were found since the last poll.
+	OutboxReasonNew OutboxReason = "new"
+	// OutboxReasonCatchUp is used for items that piled up while the bot was offline.
+	OutboxReasonCatchUp OutboxReason = "catchup"
+)
+
+type DeliveryMode string
+
+const (
+	DeliveryInstant DeliveryMode = "instant"
+	DeliveryHourly  DeliveryMode = "hourly"
+	DeliveryDaily   DeliveryMode = "daily"
+)
+
+// Guild holds a guild's settings.
+type Guild struct {
+	ID string
+	// ChannelRoles are the roles that may create subscriptions posting in the guild's channels, anyone may if empty.
+	ChannelRoles []string
+}
+
+// AllowsChannelSubscriptions returns whether a member with the given roles may create channel subscriptions.
+func (g *Guild) AllowsChannelSubscriptions(roles []string) bool {
+	if len(g.ChannelRoles) == 0 {
+		return true
+	}
+
+	for _, role := range roles {
+		if slices.Contains(g.ChannelRoles, role) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Email is a user's address for the email notifier.
+type Email struct {
+	UserID string
+	// Address is the verified address items are emailed to, empty if there is none.
+	Address string
+	// PendingAddress is waiting to be verified with Code, it replaces Address once it is.
+	PendingAddress string
+	Code           string
+	CodeExpiresAt  *time.Time
+	// CodeAttempts is how many wrong codes have been tried for PendingAddress.
+	CodeAttempts int
+}
+
+func (e *Email) IsVerified() bool {
+	return e.Address != ""
+}
+
+// Match is an item a subscription alerted on, as it was when it matched.
+type Match struct {
+	Item      sendico.Item
+	MatchedAt time.Time
+}
+
+// SubscriptionMatch is a match along with the subscription and term it was for.
+type SubscriptionMatch struct {
+	Match
+	SubscriptionID string
+	Term           Term
+}
+
+// MatchQuery filters a user's matches, zero fields don't filter.
+type MatchQuery struct {
+	UserID         string
+	SubscriptionID string
+	// Text is searched for in the item names and the English and Japanese terms.
+	Text     string
+	Shop     sendico.Shop
+	MinPrice *int
+	MaxPrice *int
+	// Since excludes items that matched before it.
+	Since  time.Time
+	Offset int
+	Limit  int
+}
+
+// Snapshot is the last known state of an item, from any search it showed up in.
+type Snapshot struct {
+	sendico.Item
+	FirstSeenAt time.Time
+	LastSeenAt  time.Time
+}
+
+// PricePoint is an item's price from when it was first seen at it.
+type PricePoint struct {
+	PriceYen int
+	PriceUSD int
+	SeenAt   time.Time
+}
+
+type PushKind string
+
+const (
+	PushNtfy   PushKind = "ntfy"
+	PushGotify PushKind = "gotify"
+)
+
+// Push is the server the push notifier publishes a user's items to.
+type Push struct {
+	UserID    string
+	Kind      PushKind
+	ServerURL string
+	// Topic is the ntfy topic to publish to, Gotify apps only have a token.
+	Topic string
+	// Token is an ntfy access token, or a Gotify app token.
+	Token string
+}
+
+func (p *Push) IsSet() bool {
+	return p.Kind != ""
+}
+
+// User holds a user's delivery state and settings.
+type User struct {
+	ID               string
+	DeliveryFailures int
+	DeliveryMode     DeliveryMode
+	// DigestHour is the local hour that daily digests are sent at.
+	DigestHour int
+	Timezone   string
+	// QuietStart and QuietEnd are local hours [start, end) during which messages are held, wrapping around midnight
+	// if start is after end.
+	QuietStart *int
+	QuietEnd   *int
+	// HourStartedAt and HourSent count the items sent to the user in the current hour long window.
+	HourStartedAt *time.Time
+	HourSent      int
+}
+
+// SentThisHour returns the number of items sent to the user in the hour before now.
+func (u *User) SentThisHour(now time.Time) int {
+	if u.HourStartedAt == nil || now.Sub(*u.HourStartedAt) >= time.Hour {
+		return 0
+	}
+	return u.HourSent
+}
+
+// NextHour returns when the user's current hour long window ends.
+func (u *User) NextHour(now time.Time) time.Time {
+	if u.HourStartedAt == nil || now.Sub(*u.HourStartedAt) >= time.Hour {
+		return now.Add(time.Hour)
+	}
+	return u.HourStartedAt.Add(time.Hour)
+}
+
+// Location returns the user's time zone, falling back to UTC if it is unset or unknown.
+func (u *User) Location() *time.Location {
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// IsQuiet returns whether t falls in the user's quiet hours.
+func (u *User) IsQuiet(t time.Time) bool {
+	if u.QuietStart == nil || u.QuietEnd == nil || *u.QuietStart == *u.QuietEnd {
+		return false
+	}
+
+	hour := t.In(u.Location()).Hour()
+	if *u.QuietStart < *u.QuietEnd {
+		return hour >= *u.QuietStart && hour < *u.QuietEnd
+	}
+	return hour >= *u.QuietStart || hour < *u.QuietEnd
+}
@@ -4,15 +4,19 @@ import (
 	"context"
 	"database/sql"
 	_ "embed"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"ariga.io/atlas/sql/migrate"
 	aschema "ariga.io/atlas/sql/schema"
 	asqlite "ariga.io/atlas/sql/sqlite"
 	"github.com/mattn/go-sqlite3"
+	"github.com/robherley/sendibot/pkg/sendico"
 )
 
 //go:embed schema.hcl
@@ -20,6 +24,8 @@ var schema []byte
 
 type SQLite struct {
 	*sql.DB
+	// fts is whether sqlite was built with FTS5, match searches fall back to LIKE without it.
+	fts bool
 }
 
 func NewSQLite(dsn string) (DB, error) {
@@ -28,7 +34,7 @@ func NewSQLite(dsn string) (DB, error) {
 		return nil, err
 	}
 
-	return &SQLite{db}, nil
+	return &SQLite{DB: db}, nil
 }
 
 func (s *SQLite) Migrate(ctx context.Context) error {
@@ -42,7 +48,8 @@ func (s *SQLite) Migrate(ctx context.Context) error {
 		return err
 	}
 
-	got, err := driver.InspectSchema(ctx, "", nil)
+	// the search index isn't in schema.hcl, atlas can't describe virtual tables
+	got, err := driver.InspectSchema(ctx, "", &aschema.InspectOptions{Exclude: []string{"match_search*"}})
 	if err != nil {
 		return err
 	}
@@ -52,7 +59,65 @@ func (s *SQLite) Migrate(ctx context.Context) error {
 		return err
 	}
 
-	return driver.ApplyChanges(ctx, changes, []migrate.PlanOption{}...)
+	if err := driver.ApplyChanges(ctx, changes, []migrate.PlanOption{}...); err != nil {
+		return err
+	}
+
+	return s.migrateSearch(ctx)
+}
+
+// migrateSearch creates the full-text index of matches, kept in sync with triggers. The trigram tokenizer matches
+// substrings, Japanese names have no spaces to split words on.
+func (s *SQLite) migrateSearch(ctx context.Context) error {
+	var exists bool
+	err := s.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE name = 'match_search')").Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		_, err := s.DB.ExecContext(ctx, "CREATE VIRTUAL TABLE match_search USING fts5(name, term_en, term_jp, tokenize = 'trigram')")
+		if err != nil && strings.Contains(err.Error(), "no such module") {
+			slog.Warn("sqlite was built without FTS5, searching matches will be slow", "err", err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	s.fts = true
+
+	statements := []string{
+		`CREATE TRIGGER IF NOT EXISTS match_search_insert AFTER INSERT ON matches BEGIN
+			INSERT INTO match_search (rowid, name, term_en, term_jp)
+			SELECT new.rowid, json_extract(new.item, '$.name'), t.en, t.jp
+			FROM subscriptions s JOIN terms t ON t.id = s.term_id
+			WHERE s.id = new.subscription_id;
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS match_search_update AFTER UPDATE OF item ON matches BEGIN
+			UPDATE match_search SET name = json_extract(new.item, '$.name') WHERE rowid = new.rowid;
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS match_search_delete AFTER DELETE ON matches BEGIN
+			DELETE FROM match_search WHERE rowid = old.rowid;
+		END`,
+	}
+	if !exists {
+		// index the matches saved before the index existed
+		statements = append(statements, `
+			INSERT INTO match_search (rowid, name, term_en, term_jp)
+			SELECT m.rowid, json_extract(m.item, '$.name'), t.en, t.jp
+			FROM matches m
+			JOIN subscriptions s ON s.id = m.subscription_id
+			JOIN terms t ON t.id = s.term_id`)
+	}
+
+	for _, statement := range statements {
+		if _, err := s.DB.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (s *SQLite) CreateTerm(term *Term) error {
@@ -94,9 +159,14 @@ func (s *SQLite) GetTerm(id string) (*Term, error) {
 
 func (s *SQLite) CreateSubscription(subscription *Subscription) error {
 	const query = `INSERT INTO subscriptions (
-		id, user_id, term_id, last_notified_at, shops, min_price, max_price
-	) VALUES (?, ?, ?, ?, ?, ?, ?)`
+		id, user_id, term_id, last_notified_at, shops, min_price, max_price,
+		poll_interval, min_poll_interval, max_poll_interval, notifiers, webhook_url, guild_id, channel_id,
+		discord_webhook_url, push_priority, deal_threshold, webhook_secret, seller_id
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	subscription.ID = newID()
+	if subscription.PollInterval == 0 {
+		subscription.PollInterval = DefaultPollInterval
+	}
 
 	_, err := s.DB.Exec(query,
 		subscription.ID,
@@ -106,6 +176,18 @@ func (s *SQLite) CreateSubscription(subscription *Subscription) error {
 		subscription.ShopsBitField,
 		subscription.MinPrice,
 		subscription.MaxPrice,
+		toSeconds(&subscription.PollInterval),
+		toSeconds(subscription.MinPollInterval),
+		toSeconds(subscription.MaxPollInterval),
+		joinNotifiers(subscription.NotifierNames()),
+		subscription.WebhookURL,
+		subscription.GuildID,
+		subscription.ChannelID,
+		subscription.DiscordWebhookURL,
+		subscription.PushPriority,
+		subscription.DealThreshold,
+		subscription.WebhookSecret,
+		subscription.SellerID,
 	)
 	if err != nil {
 		var sqliteErr sqlite3.Error
@@ -119,34 +201,21 @@ func (s *SQLite) CreateSubscription(subscription *Subscription) error {
 }
 
 func (s *SQLite) GetSubscription(id string) (*Subscription, error) {
-	const query = `
-		SELECT id, user_id, term_id, last_notified_at, shops, min_price, max_price
-		FROM subscriptions
-		WHERE id = ?
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions s
+		WHERE s.id = ?
 	`
 
-	row := s.DB.QueryRow(query, id)
-
-	subscription := &Subscription{}
-	if err := row.Scan(
-		&subscription.ID,
-		&subscription.UserID,
-		&subscription.TermID,
-		&subscription.LastNotifiedAt,
-		&subscription.ShopsBitField,
-		&subscription.MinPrice,
-		&subscription.MaxPrice,
-	); err != nil {
-		return nil, err
-	}
-
-	return subscription, nil
+	return scanSubscription(s.DB.QueryRow(query, id))
 }
 
 func (s *SQLite) UpdateSubscription(subscription *Subscription) error {
 	const query = `
 	UPDATE subscriptions
-	SET last_notified_at = ?, shops = ?, min_price = ?, max_price = ?
+	SET last_notified_at = ?, shops = ?, min_price = ?, max_price = ?, min_poll_interval = ?, max_poll_interval = ?,
+		high_water_mark = ?, notifiers = ?, webhook_url = ?, webhook_secret = ?, guild_id = ?, channel_id = ?,
+		discord_webhook_url = ?, thread_id = ?, push_priority = ?, feed_token = ?, deal_threshold = ?, seller_id = ?
 	WHERE id = ?
 	`
 
@@ -155,6 +224,20 @@ func (s *SQLite) UpdateSubscription(subscription *Subscription) error {
 		subscription.ShopsBitField,
 		subscription.MinPrice,
 		subscription.MaxPrice,
+		toSeconds(subscription.MinPollInterval),
+		toSeconds(subscription.MaxPollInterval),
+		subscription.HighWaterMark,
+		joinNotifiers(subscription.NotifierNames()),
+		subscription.WebhookURL,
+		subscription.WebhookSecret,
+		subscription.GuildID,
+		subscription.ChannelID,
+		subscription.DiscordWebhookURL,
+		subscription.ThreadID,
+		subscription.PushPriority,
+		subscription.FeedToken,
+		subscription.DealThreshold,
+		subscription.SellerID,
 		subscription.ID,
 	)
 	if err != nil {
@@ -165,8 +248,8 @@ func (s *SQLite) UpdateSubscription(subscription *Subscription) error {
 }
 
 func (s *SQLite) GetUserSubscriptions(userID string) ([]TermSubscription, error) {
-	const query = `
-		SELECT t.id, t.en, t.jp, s.id, s.user_id, s.term_id, s.last_notified_at, s.shops, s.min_price, s.max_price
+	query := `
+		SELECT t.id, t.en, t.jp, ` + subscriptionColumns + `
 		FROM subscriptions s
 		JOIN terms t ON t.id = s.term_id
 		WHERE s.user_id = ?
@@ -178,99 +261,71 @@ func (s *SQLite) GetUserSubscriptions(userID string) ([]TermSubscription, error)
 	}
 	defer rows.Close()
 
-	var subscriptions []TermSubscription
-	for rows.Next() {
-		var term Term
-		var subscription Subscription
-		if err := rows.Scan(
-			&term.ID,
-			&term.EN,
-			&term.JP,
-			&subscription.ID,
-			&subscription.UserID,
-			&subscription.TermID,
-			&subscription.LastNotifiedAt,
-			&subscription.ShopsBitField,
-			&subscription.MinPrice,
-			&subscription.MaxPrice,
-		); err != nil {
-			return nil, err
-		}
-
-		subscriptions = append(subscriptions, TermSubscription{
-			Term:         term,
-			Subscription: subscription,
-		})
-	}
-
-	return subscriptions, nil
+	return scanTermSubscriptions(rows)
 }
 
-func (s *SQLite) FindSubscriptionsToNotify(window time.Duration, limit int) ([]TermSubscription, error) {
-	const query = `
-		SELECT t.id, t.en, t.jp, s.id, s.user_id, s.term_id, s.last_notified_at, s.shops, s.min_price, s.max_price
+func (s *SQLite) FindSubscriptionsToNotify(limit int) ([]TermSubscription, error) {
+	query := `
+		SELECT t.id, t.en, t.jp, ` + subscriptionColumns + `
 		FROM subscriptions s
 		JOIN terms t ON t.id = s.term_id
-		WHERE s.last_notified_at < ?
+		WHERE s.paused_at IS NULL AND (s.next_poll_at IS NULL OR s.next_poll_at <= ?)
+		ORDER BY s.next_poll_at
 		LIMIT ?
 	`
 
-	rows, err := s.DB.Query(query, time.Now().UTC().Add(-window), limit)
+	rows, err := s.DB.Query(query, time.Now().UTC(), limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var subscriptions []TermSubscription
-	for rows.Next() {
-		var term Term
-		var subscription Subscription
-		if err := rows.Scan(
-			&term.ID,
-			&term.EN,
-			&term.JP,
-			&subscription.ID,
-			&subscription.UserID,
-			&subscription.TermID,
-			&subscription.LastNotifiedAt,
-			&subscription.ShopsBitField,
-			&subscription.MinPrice,
-			&subscription.MaxPrice,
-		); err != nil {
-			return nil, err
-		}
+	return scanTermSubscriptions(rows)
+}
 
-		subscriptions = append(subscriptions, TermSubscription{
-			Term:         term,
-			Subscription: subscription,
-		})
-	}
+func (s *SQLite) UpdateSchedule(subscription *Subscription) error {
+	const query = `
+	UPDATE subscriptions
+	SET last_notified_at = ?, poll_interval = ?, poll_reason = ?, next_poll_at = ?, new_item_rate = ?,
+		high_water_mark = ?
+	WHERE id = ?
+	`
 
-	return subscriptions, nil
+	_, err := s.DB.Exec(query,
+		subscription.LastNotifiedAt,
+		toSeconds(&subscription.PollInterval),
+		subscription.PollReason,
+		subscription.NextPollAt,
+		subscription.NewItemRate,
+		subscription.HighWaterMark,
+		subscription.ID,
+	)
+	return err
 }
 
-func (s *SQLite) SetNotified(subIDs ...string) error {
-	if len(subIDs) == 0 {
-		return nil
+// GetPollLoad returns the number of shop searches per hour that the current subscription schedules add up to.
+func (s *SQLite) GetPollLoad() (float64, error) {
+	rows, err := s.DB.Query(`SELECT shops, poll_interval FROM subscriptions WHERE paused_at IS NULL`)
+	if err != nil {
+		return 0, err
 	}
+	defer rows.Close()
 
-	query := `
-	UPDATE subscriptions
-	SET last_notified_at = ?
-	WHERE id IN (%s)`
+	load := 0.0
+	for rows.Next() {
+		var shops, interval int
+		if err := rows.Scan(&shops, &interval); err != nil {
+			return 0, err
+		}
 
-	query = fmt.Sprintf(query, strings.Repeat("?,", len(subIDs)-1)+"?")
-	args := []any{time.Now().UTC()}
-	for _, id := range subIDs {
-		args = append(args, id)
-	}
+		if interval <= 0 {
+			continue
+		}
 
-	_, err := s.DB.Exec(query, args...)
-	if err != nil {
-		return err
+		load += float64(len(sendico.ShopsFromBits(shops))) * time.Hour.Seconds() / float64(interval)
 	}
 
-	return nil
+	return load, rows.Err()
 }
 
 func (s *SQLite) DeleteUserSubscriptions(userID string, ids ...string) error {
@@ -315,30 +370,90 @@ func (s *SQLite) DeleteUserSubscriptions(userID string, ids ...string) error {
 		return err
 	}
 
+	seenDeleteQuery := `
+	DELETE FROM
+		seen_items
+	WHERE
+		subscription_id IN (%s)`
+	seenDeleteQuery = fmt.Sprintf(seenDeleteQuery, strings.Repeat("?,", len(ids)-1)+"?")
+
+	_, err = tx.Exec(seenDeleteQuery, args...)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	matchesDeleteQuery := `
+	DELETE FROM
+		matches
+	WHERE
+		subscription_id IN (%s)`
+	matchesDeleteQuery = fmt.Sprintf(matchesDeleteQuery, strings.Repeat("?,", len(ids)-1)+"?")
+
+	_, err = tx.Exec(matchesDeleteQuery, args...)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	feedbackDeleteQuery := `
+	DELETE FROM
+		feedback
+	WHERE
+		subscription_id IN (%s)`
+	feedbackDeleteQuery = fmt.Sprintf(feedbackDeleteQuery, strings.Repeat("?,", len(ids)-1)+"?")
+
+	_, err = tx.Exec(feedbackDeleteQuery, args...)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	outboxDeleteQuery := `
+	DELETE FROM
+		outbox
+	WHERE
+		subscription_id IN (%s)`
+	outboxDeleteQuery = fmt.Sprintf(outboxDeleteQuery, strings.Repeat("?,", len(ids)-1)+"?")
+
+	_, err = tx.Exec(outboxDeleteQuery, args...)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
 	return tx.Commit()
 }
 
 func (s *SQLite) TrackItems(items ...Item) error {
-	const query = `
-	INSERT INTO
-		items (id, shop, code, subscription_id, created_at)
-	VALUES (?, ?, ?, ?, ?)`
-
 	tx, err := s.DB.Begin()
 	if err != nil {
 		return err
 	}
 
+	if err := trackItems(tx, items...); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func trackItems(tx *sql.Tx, items ...Item) error {
+	const query = `
+	INSERT INTO
+		items (id, shop, code, subscription_id, created_at)
+	VALUES (?, ?, ?, ?, ?)`
+
 	for _, item := range items {
 		item.ID = newID()
-		_, err = tx.Exec(query, item.ID, item.Shop, item.Code, item.SubscriptionID, time.Now().UTC())
+		_, err := tx.Exec(query, item.ID, item.Shop, item.Code, item.SubscriptionID, time.Now().UTC())
 		if err != nil {
-			_ = tx.Rollback()
 			return err
 		}
 	}
 
-	return tx.Commit()
+	return nil
 }
 
 func (s *SQLite) FilterBySeenItems(items []Item) ([]Item, error) {
@@ -386,10 +501,1260 @@ func (s *SQLite) FilterBySeenItems(items []Item) ([]Item, error) {
 		}
 	}
 
+	return s.filterBySeenKeys(notFoundItems)
+}
+
+// filterBySeenKeys filters out items that are in the long-lived history of their subscription.
+func (s *SQLite) filterBySeenKeys(items []Item) ([]Item, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	query := `
+	SELECT
+			subscription_id, key
+	FROM
+			seen_items
+	WHERE
+			(subscription_id, key) IN (`
+
+	var args []interface{}
+	for i, item := range items {
+		if i > 0 {
+			query += ","
+		}
+		query += "(?, ?)"
+		args = append(args, item.SubscriptionID, item.Key())
+	}
+	query += ")"
+
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type seenKey struct {
+		subscriptionID string
+		key            int64
+	}
+
+	found := make(map[seenKey]struct{})
+	for rows.Next() {
+		var key seenKey
+		if err := rows.Scan(&key.subscriptionID, &key.key); err != nil {
+			return nil, err
+		}
+		found[key] = struct{}{}
+	}
+
+	var notFoundItems []Item
+	for _, item := range items {
+		if _, ok := found[seenKey{item.SubscriptionID, item.Key()}]; !ok {
+			notFoundItems = append(notFoundItems, item)
+		}
+	}
+
 	return notFoundItems, nil
 }
 
+// CleanupItems moves items older than window out of the exact recent window and into the long-lived history of their
+// subscription, which only keeps a hashed key per item.
 func (s *SQLite) CleanupItems(window time.Duration) error {
-	_, err := s.DB.Exec("DELETE FROM items WHERE created_at < ?", time.Now().UTC().Add(-window))
+	cutoff := time.Now().UTC().Add(-window)
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT shop, code, subscription_id FROM items WHERE created_at < ?", cutoff)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	var expired []Item
+	for rows.Next() {
+		var item Item
+		if err := rows.Scan(&item.Shop, &item.Code, &item.SubscriptionID); err != nil {
+			rows.Close()
+			_ = tx.Rollback()
+			return err
+		}
+		expired = append(expired, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	for _, item := range expired {
+		_, err := tx.Exec("INSERT OR IGNORE INTO seen_items (subscription_id, key) VALUES (?, ?)", item.SubscriptionID, item.Key())
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM items WHERE created_at < ?", cutoff); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// EnqueueItems tracks items as seen and adds the entries to the outbox in the same transaction, so new items are never
+// marked as seen without also being queued for delivery.
+func (s *SQLite) EnqueueItems(entries []*OutboxEntry, items ...Item) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := trackItems(tx, items...); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := insertOutbox(tx, entry); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func insertOutbox(tx *sql.Tx, entry *OutboxEntry) error {
+	const query = `
+	INSERT INTO
+		outbox (id, subscription_id, items, attempts, next_attempt_at, created_at, digest, reason, notifier, notes)
+	VALUES (?, ?, ?, 0, ?, ?, ?, ?, ?, ?)`
+
+	itemsJSON, err := json.Marshal(entry.Items)
+	if err != nil {
+		return err
+	}
+
+	notes := entry.Notes
+	if notes == nil {
+		notes = map[string]ItemNote{}
+	}
+	notesJSON, err := json.Marshal(notes)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	entry.ID = newID()
+	entry.CreatedAt = now
+	if entry.NextAttemptAt.IsZero() {
+		entry.NextAttemptAt = now
+	}
+	if entry.Reason == "" {
+		entry.Reason = OutboxReasonNew
+	}
+	if entry.Notifier == "" {
+		entry.Notifier = DefaultNotifier
+	}
+
+	_, err = tx.Exec(query, entry.ID, entry.SubscriptionID, string(itemsJSON), entry.NextAttemptAt.UTC(), entry.CreatedAt,
+		entry.Digest, entry.Reason, entry.Notifier, string(notesJSON))
 	return err
 }
+
+func (s *SQLite) FindPendingOutbox(limit int) ([]OutboxEntry, error) {
+	query := `
+		SELECT o.id, o.subscription_id, o.items, o.attempts, o.next_attempt_at, o.last_error, o.created_at, o.sent_at,
+			o.digest, o.reason, o.notifier, o.notes, t.id, t.en, t.jp, ` + subscriptionColumns + `
+		FROM outbox o
+		JOIN subscriptions s ON s.id = o.subscription_id
+		JOIN terms t ON t.id = s.term_id
+		WHERE o.sent_at IS NULL AND o.next_attempt_at <= ? AND s.paused_at IS NULL
+		ORDER BY o.created_at
+		LIMIT ?
+	`
+
+	rows, err := s.DB.Query(query, time.Now().UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []OutboxEntry
+	for rows.Next() {
+		var (
+			entry     OutboxEntry
+			itemsJSON string
+			notesJSON string
+		)
+
+		subscription, err := scanSubscription(rows,
+			&entry.ID,
+			&entry.SubscriptionID,
+			&itemsJSON,
+			&entry.Attempts,
+			&entry.NextAttemptAt,
+			&entry.LastError,
+			&entry.CreatedAt,
+			&entry.SentAt,
+			&entry.Digest,
+			&entry.Reason,
+			&entry.Notifier,
+			&notesJSON,
+			&entry.Term.ID,
+			&entry.Term.EN,
+			&entry.Term.JP,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(itemsJSON), &entry.Items); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(notesJSON), &entry.Notes); err != nil {
+			return nil, err
+		}
+
+		entry.Subscription = *subscription
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (s *SQLite) MarkOutboxSent(id string) error {
+	_, err := s.DB.Exec("UPDATE outbox SET sent_at = ? WHERE id = ?", time.Now().UTC(), id)
+	return err
+}
+
+func (s *SQLite) MarkOutboxFailed(id string, reason string, next time.Time) error {
+	const query = `
+	UPDATE outbox
+	SET attempts = attempts + 1, last_error = ?, next_attempt_at = ?
+	WHERE id = ?
+	`
+
+	_, err := s.DB.Exec(query, reason, next.UTC(), id)
+	return err
+}
+
+// CleanupOutbox removes entries delivered more than sent ago, and entries that still haven't been delivered more than
+// unsent after they were queued.
+func (s *SQLite) CleanupOutbox(sent, unsent time.Duration) error {
+	now := time.Now().UTC()
+	_, err := s.DB.Exec("DELETE FROM outbox WHERE sent_at < ? OR (sent_at IS NULL AND created_at < ?)", now.Add(-sent), now.Add(-unsent))
+	return err
+}
+
+// RecordDeliveryFailure counts a failed delivery to the user and returns the number of consecutive failures.
+func (s *SQLite) RecordDeliveryFailure(userID string) (int, error) {
+	const query = `
+	INSERT INTO users (id, delivery_failures, last_failure_at) VALUES (?, 1, ?)
+	ON CONFLICT (id) DO UPDATE SET delivery_failures = delivery_failures + 1, last_failure_at = excluded.last_failure_at
+	RETURNING delivery_failures`
+
+	var failures int
+	if err := s.DB.QueryRow(query, userID, time.Now().UTC()).Scan(&failures); err != nil {
+		return 0, err
+	}
+
+	return failures, nil
+}
+
+func (s *SQLite) ResetDeliveryFailures(userID string) error {
+	_, err := s.DB.Exec("UPDATE users SET delivery_failures = 0 WHERE id = ? AND delivery_failures > 0", userID)
+	return err
+}
+
+// PauseUserSubscriptions pauses the user's subscriptions that deliver to their DMs.
+func (s *SQLite) PauseUserSubscriptions(userID string, reason PauseReason) error {
+	const query = `
+	UPDATE subscriptions
+	SET paused_at = ?, pause_reason = ?
+	WHERE user_id = ? AND paused_at IS NULL AND ',' || notifiers || ',' LIKE '%,' || ? || ',%'
+	`
+
+	_, err := s.DB.Exec(query, time.Now().UTC(), reason, userID, DefaultNotifier)
+	return err
+}
+
+func (s *SQLite) PauseSubscription(id string, reason PauseReason) error {
+	const query = `
+	UPDATE subscriptions
+	SET paused_at = ?, pause_reason = ?
+	WHERE id = ? AND paused_at IS NULL
+	`
+
+	_, err := s.DB.Exec(query, time.Now().UTC(), reason, id)
+	return err
+}
+
+// ResumeSubscription resumes the subscription whatever it was paused for, and makes its pending outbox entries due
+// right away.
+func (s *SQLite) ResumeSubscription(id string) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE subscriptions SET paused_at = NULL, pause_reason = NULL WHERE id = ?", id); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE outbox SET next_attempt_at = ? WHERE sent_at IS NULL AND subscription_id = ?", time.Now().UTC(), id); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ResumeUserSubscriptions resumes the user's subscriptions that were paused for the given reason, and makes their
+// pending outbox entries due right away. It returns the number of subscriptions resumed.
+func (s *SQLite) ResumeUserSubscriptions(userID string, reason PauseReason) (int, error) {
+	const resumeQuery = `
+	UPDATE subscriptions
+	SET paused_at = NULL, pause_reason = NULL
+	WHERE user_id = ? AND pause_reason = ?
+	`
+
+	const outboxQuery = `
+	UPDATE outbox
+	SET next_attempt_at = ?
+	WHERE sent_at IS NULL AND subscription_id IN (SELECT id FROM subscriptions WHERE user_id = ?)
+	`
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec(resumeQuery, userID, reason)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+
+	resumed, err := res.RowsAffected()
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+
+	if resumed == 0 {
+		return 0, tx.Rollback()
+	}
+
+	if _, err := tx.Exec(outboxQuery, time.Now().UTC(), userID); err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+
+	if _, err := tx.Exec("UPDATE users SET delivery_failures = 0 WHERE id = ?", userID); err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+
+	return int(resumed), tx.Commit()
+}
+
+// GetUser returns the user, or a user with the default settings if they haven't been stored yet.
+func (s *SQLite) GetUser(id string) (*User, error) {
+	const query = `
+		SELECT id, delivery_failures, delivery_mode, digest_hour, timezone, quiet_start, quiet_end, hour_started_at, hour_sent
+		FROM users
+		WHERE id = ?
+	`
+
+	user := &User{}
+	err := s.DB.QueryRow(query, id).Scan(
+		&user.ID,
+		&user.DeliveryFailures,
+		&user.DeliveryMode,
+		&user.DigestHour,
+		&user.Timezone,
+		&user.QuietStart,
+		&user.QuietEnd,
+		&user.HourStartedAt,
+		&user.HourSent,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &User{
+			ID:           id,
+			DeliveryMode: DeliveryInstant,
+			DigestHour:   9,
+			Timezone:     "UTC",
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *SQLite) UpdateUserSettings(user *User) error {
+	const query = `
+	INSERT INTO users (id, delivery_mode, digest_hour, timezone, quiet_start, quiet_end) VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT (id) DO UPDATE SET
+		delivery_mode = excluded.delivery_mode,
+		digest_hour = excluded.digest_hour,
+		timezone = excluded.timezone,
+		quiet_start = excluded.quiet_start,
+		quiet_end = excluded.quiet_end`
+
+	_, err := s.DB.Exec(query,
+		user.ID,
+		user.DeliveryMode,
+		user.DigestHour,
+		user.Timezone,
+		user.QuietStart,
+		user.QuietEnd,
+	)
+	return err
+}
+
+// GetGuild returns a guild's settings, or the defaults if none have been saved.
+func (s *SQLite) GetGuild(id string) (*Guild, error) {
+	var channelRoles string
+	err := s.DB.QueryRow("SELECT channel_roles FROM guilds WHERE id = ?", id).Scan(&channelRoles)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Guild{ID: id}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	guild := &Guild{ID: id}
+	if channelRoles != "" {
+		guild.ChannelRoles = strings.Split(channelRoles, ",")
+	}
+
+	return guild, nil
+}
+
+func (s *SQLite) UpdateGuild(guild *Guild) error {
+	const query = `
+	INSERT INTO guilds (id, channel_roles) VALUES (?, ?)
+	ON CONFLICT (id) DO UPDATE SET channel_roles = excluded.channel_roles`
+
+	_, err := s.DB.Exec(query, guild.ID, strings.Join(guild.ChannelRoles, ","))
+	return err
+}
+
+// GetEmail returns the user's email address and verification state, or an empty one if they haven't set an address.
+func (s *SQLite) GetEmail(userID string) (*Email, error) {
+	const query = `
+		SELECT address, pending_address, code, code_expires_at, code_attempts
+		FROM emails
+		WHERE user_id = ?
+	`
+
+	email := &Email{UserID: userID}
+	err := s.DB.QueryRow(query, userID).Scan(
+		&email.Address,
+		&email.PendingAddress,
+		&email.Code,
+		&email.CodeExpiresAt,
+		&email.CodeAttempts,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return email, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return email, nil
+}
+
+func (s *SQLite) UpdateEmail(email *Email) error {
+	const query = `
+	INSERT INTO emails (user_id, address, pending_address, code, code_expires_at, code_attempts) VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT (user_id) DO UPDATE SET
+		address = excluded.address,
+		pending_address = excluded.pending_address,
+		code = excluded.code,
+		code_expires_at = excluded.code_expires_at,
+		code_attempts = excluded.code_attempts`
+
+	var expiresAt *time.Time
+	if email.CodeExpiresAt != nil {
+		utc := email.CodeExpiresAt.UTC()
+		expiresAt = &utc
+	}
+
+	_, err := s.DB.Exec(query,
+		email.UserID,
+		email.Address,
+		email.PendingAddress,
+		email.Code,
+		expiresAt,
+		email.CodeAttempts,
+	)
+	return err
+}
+
+func (s *SQLite) DeleteEmail(userID string) error {
+	_, err := s.DB.Exec("DELETE FROM emails WHERE user_id = ?", userID)
+	return err
+}
+
+// GetSubscriptionByFeedToken returns the subscription whose feed has the token, ErrNotFound if there is none.
+func (s *SQLite) GetSubscriptionByFeedToken(token string) (*TermSubscription, error) {
+	query := `
+		SELECT t.id, t.en, t.jp, ` + subscriptionColumns + `
+		FROM subscriptions s
+		JOIN terms t ON t.id = s.term_id
+		WHERE s.feed_token = ?
+	`
+
+	var term Term
+	subscription, err := scanSubscription(s.DB.QueryRow(query, token), &term.ID, &term.EN, &term.JP)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &TermSubscription{Term: term, Subscription: *subscription}, nil
+}
+
+// SaveMatches stores the items a subscription alerted on, replacing older snapshots of the same items.
+func (s *SQLite) SaveMatches(subscriptionID string, items ...sendico.Item) error {
+	const query = `
+	INSERT INTO matches (subscription_id, shop, code, item, matched_at) VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT (subscription_id, shop, code) DO UPDATE SET item = excluded.item, matched_at = excluded.matched_at`
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, item := range items {
+		itemJSON, err := json.Marshal(item)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+
+		if _, err := tx.Exec(query, subscriptionID, item.Shop, item.Code, string(itemJSON), now); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetMatches returns the subscription's most recent matches, newest first.
+func (s *SQLite) GetMatches(subscriptionID string, limit int) ([]Match, error) {
+	const query = `
+		SELECT item, matched_at
+		FROM matches
+		WHERE subscription_id = ?
+		ORDER BY matched_at DESC, rowid DESC
+		LIMIT ?
+	`
+
+	rows, err := s.DB.Query(query, subscriptionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []Match
+	for rows.Next() {
+		var (
+			match    Match
+			itemJSON string
+		)
+		if err := rows.Scan(&itemJSON, &match.MatchedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(itemJSON), &match.Item); err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+
+	return matches, rows.Err()
+}
+
+// CleanupMatches removes matches older than the window.
+func (s *SQLite) CleanupMatches(window time.Duration) error {
+	_, err := s.DB.Exec("DELETE FROM matches WHERE matched_at < ?", time.Now().UTC().Add(-window))
+	return err
+}
+
+// SearchMatches returns a page of the user's matches that pass the query, newest first, and how many there are in
+// total.
+func (s *SQLite) SearchMatches(query MatchQuery) ([]SubscriptionMatch, int, error) {
+	where := []string{"s.user_id = ?"}
+	args := []any{query.UserID}
+
+	if query.SubscriptionID != "" {
+		where = append(where, "m.subscription_id = ?")
+		args = append(args, query.SubscriptionID)
+	}
+
+	if text := strings.TrimSpace(query.Text); text != "" {
+		switch {
+		case s.fts && utf8.RuneCountInString(text) >= 3:
+			// a quoted phrase, so the text is matched as is rather than as a query
+			where = append(where, "m.rowid IN (SELECT rowid FROM match_search WHERE match_search MATCH ?)")
+			args = append(args, `"`+strings.ReplaceAll(text, `"`, `""`)+`"`)
+		case s.fts:
+			// trigrams can't match less than three characters, the index still has to be scanned
+			where = append(where, "m.rowid IN (SELECT rowid FROM match_search WHERE name LIKE ? OR term_en LIKE ? OR term_jp LIKE ?)")
+			pattern := "%" + text + "%"
+			args = append(args, pattern, pattern, pattern)
+		default:
+			where = append(where, "(json_extract(m.item, '$.name') LIKE ? OR t.en LIKE ? OR t.jp LIKE ?)")
+			pattern := "%" + text + "%"
+			args = append(args, pattern, pattern, pattern)
+		}
+	}
+
+	if query.Shop != 0 {
+		where = append(where, "m.shop = ?")
+		args = append(args, query.Shop)
+	}
+
+	if query.MinPrice != nil {
+		where = append(where, "json_extract(m.item, '$.price') >= ?")
+		args = append(args, *query.MinPrice)
+	}
+
+	if query.MaxPrice != nil {
+		where = append(where, "json_extract(m.item, '$.price') <= ?")
+		args = append(args, *query.MaxPrice)
+	}
+
+	if !query.Since.IsZero() {
+		where = append(where, "m.matched_at >= ?")
+		args = append(args, query.Since.UTC())
+	}
+
+	from := `
+		FROM matches m
+		JOIN subscriptions s ON s.id = m.subscription_id
+		JOIN terms t ON t.id = s.term_id
+		WHERE ` + strings.Join(where, " AND ")
+
+	var total int
+	if err := s.DB.QueryRow("SELECT COUNT(*)"+from, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.DB.Query("SELECT m.item, m.matched_at, s.id, t.id, t.en, t.jp"+from+" ORDER BY m.matched_at DESC, m.rowid DESC LIMIT ? OFFSET ?",
+		append(args, query.Limit, query.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var matches []SubscriptionMatch
+	for rows.Next() {
+		var (
+			match    SubscriptionMatch
+			itemJSON string
+		)
+		if err := rows.Scan(&itemJSON, &match.MatchedAt, &match.SubscriptionID, &match.Term.ID, &match.Term.EN, &match.Term.JP); err != nil {
+			return nil, 0, err
+		}
+		if err := json.Unmarshal([]byte(itemJSON), &match.Item); err != nil {
+			return nil, 0, err
+		}
+		matches = append(matches, match)
+	}
+
+	return matches, total, rows.Err()
+}
+
+// SaveSnapshots records the current state of items found searching for a term, adding to their price history if their
+// price changed.
+func (s *SQLite) SaveSnapshots(termID string, items ...sendico.Item) error {
+	const historyQuery = `
+	INSERT INTO price_history (shop, code, price_yen, price_usd, seen_at)
+	SELECT ?, ?, ?, ?, ?
+	WHERE NOT EXISTS (SELECT 1 FROM item_snapshots WHERE shop = ? AND code = ? AND price_yen = ?)
+	ON CONFLICT DO NOTHING`
+
+	const snapshotQuery = `
+	INSERT INTO item_snapshots (shop, code, name, url, image, price_yen, price_usd, category, labels, auction, first_seen_at, last_seen_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (shop, code) DO UPDATE SET
+		name = excluded.name,
+		url = excluded.url,
+		image = excluded.image,
+		image_hash = CASE WHEN image = excluded.image THEN image_hash END,
+		price_yen = excluded.price_yen,
+		price_usd = excluded.price_usd,
+		category = excluded.category,
+		labels = excluded.labels,
+		auction = excluded.auction,
+		last_seen_at = excluded.last_seen_at`
+
+	const termQuery = `INSERT INTO term_items (term_id, shop, code) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, item := range items {
+		labels, err := json.Marshal(item.Labels)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+
+		var auction *string
+		if item.Auction != nil {
+			auctionJSON, err := json.Marshal(item.Auction)
+			if err != nil {
+				_ = tx.Rollback()
+				return err
+			}
+			value := string(auctionJSON)
+			auction = &value
+		}
+
+		var category *string
+		if item.Category != nil {
+			value := item.Category.String()
+			category = &value
+		}
+
+		if _, err := tx.Exec(historyQuery, item.Shop, item.Code, item.PriceYen, item.PriceUSD, now, item.Shop, item.Code, item.PriceYen); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+
+		_, err = tx.Exec(snapshotQuery, item.Shop, item.Code, item.Name, item.URL, item.Image, item.PriceYen, item.PriceUSD,
+			category, string(labels), auction, now, now)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+
+		if _, err := tx.Exec(termQuery, termID, item.Shop, item.Code); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetSnapshot returns the last known state of an item, ErrNotFound if it was never seen.
+func (s *SQLite) GetSnapshot(shop sendico.Shop, code string) (*Snapshot, error) {
+	const query = `
+		SELECT shop, code, name, url, image, price_yen, price_usd, category, labels, auction, first_seen_at, last_seen_at
+		FROM item_snapshots
+		WHERE shop = ? AND code = ?
+	`
+
+	snapshot, err := scanSnapshot(s.DB.QueryRow(query, shop, code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return snapshot, err
+}
+
+// scanSnapshot scans the item_snapshots columns from row, in table order.
+func scanSnapshot(row scanner) (*Snapshot, error) {
+	var (
+		snapshot Snapshot
+		category *string
+		labels   string
+		auction  *string
+	)
+
+	err := row.Scan(
+		&snapshot.Shop,
+		&snapshot.Code,
+		&snapshot.Name,
+		&snapshot.URL,
+		&snapshot.Image,
+		&snapshot.PriceYen,
+		&snapshot.PriceUSD,
+		&category,
+		&labels,
+		&auction,
+		&snapshot.FirstSeenAt,
+		&snapshot.LastSeenAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if category != nil {
+		number := json.Number(*category)
+		snapshot.Category = &number
+	}
+
+	if err := json.Unmarshal([]byte(labels), &snapshot.Labels); err != nil {
+		return nil, err
+	}
+
+	if auction != nil {
+		snapshot.Auction = &sendico.Auction{}
+		if err := json.Unmarshal([]byte(*auction), snapshot.Auction); err != nil {
+			return nil, err
+		}
+	}
+
+	return &snapshot, nil
+}
+
+// GetPriceHistory returns the prices an item was seen at, oldest first.
+func (s *SQLite) GetPriceHistory(shop sendico.Shop, code string) ([]PricePoint, error) {
+	rows, err := s.DB.Query("SELECT price_yen, price_usd, seen_at FROM price_history WHERE shop = ? AND code = ? ORDER BY seen_at", shop, code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []PricePoint
+	for rows.Next() {
+		var point PricePoint
+		if err := rows.Scan(&point.PriceYen, &point.PriceUSD, &point.SeenAt); err != nil {
+			return nil, err
+		}
+		history = append(history, point)
+	}
+
+	return history, rows.Err()
+}
+
+// CleanupSnapshots removes items, and their price history, that haven't been seen within the window.
+func (s *SQLite) CleanupSnapshots(window time.Duration) error {
+	const historyQuery = `
+	DELETE FROM price_history WHERE (shop, code) IN (SELECT shop, code FROM item_snapshots WHERE last_seen_at < ?)`
+	const termsQuery = `
+	DELETE FROM term_items WHERE (shop, code) IN (SELECT shop, code FROM item_snapshots WHERE last_seen_at < ?)`
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().UTC().Add(-window)
+	if _, err := tx.Exec(historyQuery, cutoff); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec(termsQuery, cutoff); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM item_snapshots WHERE last_seen_at < ?", cutoff); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetTermPrices returns the last known yen prices of the items found searching for a term that were seen since a time,
+// by shop.
+func (s *SQLite) GetTermPrices(termID string, since time.Time) (map[sendico.Shop][]int, error) {
+	const query = `
+		SELECT s.shop, s.price_yen
+		FROM term_items ti
+		JOIN item_snapshots s ON s.shop = ti.shop AND s.code = ti.code
+		WHERE ti.term_id = ? AND s.last_seen_at >= ?
+	`
+
+	rows, err := s.DB.Query(query, termID, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := make(map[sendico.Shop][]int)
+	for rows.Next() {
+		var (
+			shop  sendico.Shop
+			price int
+		)
+		if err := rows.Scan(&shop, &price); err != nil {
+			return nil, err
+		}
+		prices[shop] = append(prices[shop], price)
+	}
+
+	return prices, rows.Err()
+}
+
+// GetFirstSeen returns when items were first seen in any search, keyed by NoteKey. Items without a snapshot are left
+// out.
+func (s *SQLite) GetFirstSeen(items ...sendico.Item) (map[string]time.Time, error) {
+	firstSeen := make(map[string]time.Time, len(items))
+	if len(items) == 0 {
+		return firstSeen, nil
+	}
+
+	query := `
+		SELECT shop, code, first_seen_at
+		FROM item_snapshots
+		WHERE (shop, code) IN (VALUES %s)
+	`
+	query = fmt.Sprintf(query, strings.Repeat("(?, ?),", len(items)-1)+"(?, ?)")
+
+	args := make([]any, 0, len(items)*2)
+	for _, item := range items {
+		args = append(args, item.Shop, item.Code)
+	}
+
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			item sendico.Item
+			at   time.Time
+		)
+		if err := rows.Scan(&item.Shop, &item.Code, &at); err != nil {
+			return nil, err
+		}
+		firstSeen[NoteKey(item)] = at
+	}
+
+	return firstSeen, rows.Err()
+}
+
+// GetImageHashes returns the image hashes stored for items, keyed by NoteKey. Items whose image hasn't been hashed yet
+// are left out.
+func (s *SQLite) GetImageHashes(items ...sendico.Item) (map[string]uint64, error) {
+	hashes := make(map[string]uint64, len(items))
+	if len(items) == 0 {
+		return hashes, nil
+	}
+
+	query := `
+		SELECT shop, code, image_hash
+		FROM item_snapshots
+		WHERE image_hash IS NOT NULL AND (shop, code) IN (VALUES %s)
+	`
+	query = fmt.Sprintf(query, strings.Repeat("(?, ?),", len(items)-1)+"(?, ?)")
+
+	args := make([]any, 0, len(items)*2)
+	for _, item := range items {
+		args = append(args, item.Shop, item.Code)
+	}
+
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			item sendico.Item
+			hash int64
+		)
+		if err := rows.Scan(&item.Shop, &item.Code, &hash); err != nil {
+			return nil, err
+		}
+		hashes[NoteKey(item)] = uint64(hash)
+	}
+
+	return hashes, rows.Err()
+}
+
+// SaveImageHash stores the hash of an item's image, it is cleared if the item's image changes.
+func (s *SQLite) SaveImageHash(item sendico.Item, hash uint64) error {
+	// sqlite integers are signed, the bits are stored as is
+	_, err := s.DB.Exec("UPDATE item_snapshots SET image_hash = ? WHERE shop = ? AND code = ?", int64(hash), item.Shop, item.Code)
+	return err
+}
+
+// SaveFeedback records a user's vote on an item for each of their subscriptions that matched it, replacing any earlier
+// vote. It returns the number of subscriptions the vote was recorded for, none if the item isn't one of their matches.
+func (s *SQLite) SaveFeedback(userID string, shop sendico.Shop, code string, vote Vote) (int, error) {
+	const query = `
+	INSERT INTO feedback (subscription_id, shop, code, name, vote, voted_at)
+	SELECT m.subscription_id, m.shop, m.code, json_extract(m.item, '$.name'), ?, ?
+	FROM matches m
+	JOIN subscriptions s ON s.id = m.subscription_id
+	WHERE s.user_id = ? AND m.shop = ? AND m.code = ?
+	ON CONFLICT (subscription_id, shop, code) DO UPDATE SET
+		vote = excluded.vote,
+		voted_at = excluded.voted_at`
+
+	result, err := s.DB.Exec(query, vote, time.Now().UTC(), userID, shop, code)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// GetFeedback returns the votes on a subscription's matches, newest first.
+func (s *SQLite) GetFeedback(subscriptionID string) ([]Feedback, error) {
+	rows, err := s.DB.Query("SELECT name, vote, voted_at FROM feedback WHERE subscription_id = ? ORDER BY voted_at DESC", subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var feedback []Feedback
+	for rows.Next() {
+		var f Feedback
+		if err := rows.Scan(&f.Name, &f.Vote, &f.VotedAt); err != nil {
+			return nil, err
+		}
+		feedback = append(feedback, f)
+	}
+
+	return feedback, rows.Err()
+}
+
+// SaveSeller follows or blocks a seller for a user, replacing whether they followed or blocked them before.
+func (s *SQLite) SaveSeller(userID string, seller Seller) error {
+	const query = `
+	INSERT INTO sellers (user_id, shop, seller_id, status, created_at)
+	VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT (user_id, shop, seller_id) DO UPDATE SET
+		status = excluded.status,
+		created_at = excluded.created_at`
+
+	_, err := s.DB.Exec(query, userID, seller.Shop, seller.ID, seller.Status, time.Now().UTC())
+	return err
+}
+
+// GetSellers returns the sellers a user follows or blocks, oldest first.
+func (s *SQLite) GetSellers(userID string) ([]Seller, error) {
+	rows, err := s.DB.Query("SELECT shop, seller_id, status, created_at FROM sellers WHERE user_id = ? ORDER BY created_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sellers []Seller
+	for rows.Next() {
+		var seller Seller
+		if err := rows.Scan(&seller.Shop, &seller.ID, &seller.Status, &seller.CreatedAt); err != nil {
+			return nil, err
+		}
+		sellers = append(sellers, seller)
+	}
+
+	return sellers, rows.Err()
+}
+
+// DeleteSeller forgets a seller a user follows or blocks.
+func (s *SQLite) DeleteSeller(userID string, shop sendico.Shop, sellerID string) error {
+	_, err := s.DB.Exec("DELETE FROM sellers WHERE user_id = ? AND shop = ? AND seller_id = ?", userID, shop, sellerID)
+	return err
+}
+
+// GetPush returns the user's push server, or an unset one if they haven't configured it.
+func (s *SQLite) GetPush(userID string) (*Push, error) {
+	push := &Push{UserID: userID}
+	err := s.DB.QueryRow("SELECT kind, server_url, topic, token FROM pushes WHERE user_id = ?", userID).Scan(
+		&push.Kind,
+		&push.ServerURL,
+		&push.Topic,
+		&push.Token,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return push, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return push, nil
+}
+
+func (s *SQLite) UpdatePush(push *Push) error {
+	const query = `
+	INSERT INTO pushes (user_id, kind, server_url, topic, token) VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT (user_id) DO UPDATE SET
+		kind = excluded.kind,
+		server_url = excluded.server_url,
+		topic = excluded.topic,
+		token = excluded.token`
+
+	_, err := s.DB.Exec(query, push.UserID, push.Kind, push.ServerURL, push.Topic, push.Token)
+	return err
+}
+
+func (s *SQLite) DeletePush(userID string) error {
+	_, err := s.DB.Exec("DELETE FROM pushes WHERE user_id = ?", userID)
+	return err
+}
+
+// CountSent adds count to the items sent to the user in the current hour, starting a new hour if the last one is over.
+func (s *SQLite) CountSent(userID string, count int) error {
+	const query = `
+	INSERT INTO users (id, hour_started_at, hour_sent) VALUES (?, ?, ?)
+	ON CONFLICT (id) DO UPDATE SET
+		hour_sent = CASE WHEN hour_started_at IS NULL OR hour_started_at < ? THEN excluded.hour_sent ELSE hour_sent + excluded.hour_sent END,
+		hour_started_at = CASE WHEN hour_started_at IS NULL OR hour_started_at < ? THEN excluded.hour_started_at ELSE hour_started_at END`
+
+	now := time.Now().UTC()
+	hourAgo := now.Add(-time.Hour)
+	_, err := s.DB.Exec(query, userID, now, count, hourAgo, hourAgo)
+	return err
+}
+
+// SplitOutbox keeps only the given items on an outbox entry and moves the rest to a new entry, in one transaction. If no
+// items are kept the original entry is removed.
+func (s *SQLite) SplitOutbox(id string, keep []sendico.Item, spill *OutboxEntry) error {
+	keepJSON, err := json.Marshal(keep)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	if len(keep) == 0 {
+		_, err = tx.Exec("DELETE FROM outbox WHERE id = ?", id)
+	} else {
+		_, err = tx.Exec("UPDATE outbox SET items = ? WHERE id = ?", string(keepJSON), id)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := insertOutbox(tx, spill); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// subscriptionColumns are the subscription columns read by scanSubscription, in order.
+const subscriptionColumns = `s.id, s.user_id, s.term_id, s.last_notified_at, s.shops, s.min_price, s.max_price,
+	s.poll_interval, s.poll_reason, s.min_poll_interval, s.max_poll_interval, s.next_poll_at, s.new_item_rate,
+	s.paused_at, s.pause_reason, s.high_water_mark, s.notifiers, s.webhook_url, s.guild_id, s.channel_id,
+	s.discord_webhook_url, s.thread_id, s.push_priority, s.feed_token, s.deal_threshold, s.webhook_secret,
+	s.seller_id`
+
+func joinNotifiers(names []string) string {
+	return strings.Join(names, ",")
+}
+
+func splitNotifiers(names string) []string {
+	if names == "" {
+		return nil
+	}
+	return strings.Split(names, ",")
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSubscription scans subscriptionColumns from row, any extra destinations are scanned first.
+func scanSubscription(row scanner, dest ...any) (*Subscription, error) {
+	var (
+		subscription    Subscription
+		pollInterval    int64
+		minPollInterval *int64
+		maxPollInterval *int64
+		notifiers       string
+	)
+
+	if err := row.Scan(append(dest,
+		&subscription.ID,
+		&subscription.UserID,
+		&subscription.TermID,
+		&subscription.LastNotifiedAt,
+		&subscription.ShopsBitField,
+		&subscription.MinPrice,
+		&subscription.MaxPrice,
+		&pollInterval,
+		&subscription.PollReason,
+		&minPollInterval,
+		&maxPollInterval,
+		&subscription.NextPollAt,
+		&subscription.NewItemRate,
+		&subscription.PausedAt,
+		&subscription.PauseReason,
+		&subscription.HighWaterMark,
+		&notifiers,
+		&subscription.WebhookURL,
+		&subscription.GuildID,
+		&subscription.ChannelID,
+		&subscription.DiscordWebhookURL,
+		&subscription.ThreadID,
+		&subscription.PushPriority,
+		&subscription.FeedToken,
+		&subscription.DealThreshold,
+		&subscription.WebhookSecret,
+		&subscription.SellerID,
+	)...); err != nil {
+		return nil, err
+	}
+
+	subscription.Notifiers = splitNotifiers(notifiers)
+
+	subscription.PollInterval = time.Duration(pollInterval) * time.Second
+	subscription.MinPollInterval = fromSeconds(minPollInterval)
+	subscription.MaxPollInterval = fromSeconds(maxPollInterval)
+
+	return &subscription, nil
+}
+
+func scanTermSubscriptions(rows *sql.Rows) ([]TermSubscription, error) {
+	var subscriptions []TermSubscription
+	for rows.Next() {
+		var term Term
+		subscription, err := scanSubscription(rows, &term.ID, &term.EN, &term.JP)
+		if err != nil {
+			return nil, err
+		}
+
+		subscriptions = append(subscriptions, TermSubscription{
+			Term:         term,
+			Subscription: *subscription,
+		})
+	}
+
+	return subscriptions, rows.Err()
+}
+
+func toSeconds(d *time.Duration) *int64 {
+	if d == nil {
+		return nil
+	}
+	seconds := int64(d.Seconds())
+	return &seconds
+}
+
+func fromSeconds(seconds *int64) *time.Duration {
+	if seconds == nil {
+		return nil
+	}
+	d := time.Duration(*seconds) * time.Second
+	return &d
+}
@@ -0,0 +1,158 @@
+package db_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/pkg/sendico"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDB(t *testing.T) db.DB {
+	t.Helper()
+
+	d, err := db.NewSQLite(filepath.Join(t.TempDir(), "sendibot.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = d.Close() })
+
+	require.NoError(t, d.Migrate(context.Background()))
+	return d
+}
+
+func TestOutbox(t *testing.T) {
+	d := newTestDB(t)
+
+	term := &db.Term{EN: "pikachu", JP: "ピカチュウ"}
+	require.NoError(t, d.CreateTerm(term))
+
+	sub := &db.Subscription{UserID: "user", TermID: term.ID}
+	sub.AddShop(sendico.Mercari)
+	require.NoError(t, d.CreateSubscription(sub))
+
+	item := sendico.Item{Shop: sendico.Mercari, Code: "m1", Name: "ピカチュウ"}
+	entry := &db.OutboxEntry{
+		SubscriptionID: sub.ID,
+		Items:          []sendico.Item{item},
+		NextAttemptAt:  time.Now().Add(-time.Second),
+		Reason:         db.OutboxReasonNew,
+		Notifier:       db.DefaultNotifier,
+	}
+	require.NoError(t, d.EnqueueItems([]*db.OutboxEntry{entry}, db.Item{Shop: item.Shop, Code: item.Code, SubscriptionID: sub.ID}))
+
+	pending, err := d.FindPendingOutbox(10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, entry.ID, pending[0].ID)
+	assert.Equal(t, 0, pending[0].Attempts)
+	assert.Equal(t, []sendico.Item{item}, pending[0].Items)
+	assert.Equal(t, term.EN, pending[0].Term.EN)
+
+	t.Run("failed entries wait for their backoff", func(t *testing.T) {
+		require.NoError(t, d.MarkOutboxFailed(entry.ID, "boom", time.Now().Add(time.Hour)))
+
+		pending, err := d.FindPendingOutbox(10)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("failed entries are retried once due", func(t *testing.T) {
+		require.NoError(t, d.MarkOutboxFailed(entry.ID, "boom again", time.Now().Add(-time.Second)))
+
+		pending, err := d.FindPendingOutbox(10)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, 2, pending[0].Attempts)
+		assert.Equal(t, "boom again", *pending[0].LastError)
+	})
+
+	t.Run("paused subscriptions are held", func(t *testing.T) {
+		require.NoError(t, d.PauseSubscription(sub.ID, db.PauseReasonNotifierDisabled))
+
+		pending, err := d.FindPendingOutbox(10)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+
+		require.NoError(t, d.ResumeSubscription(sub.ID))
+	})
+
+	t.Run("delivered entries are done", func(t *testing.T) {
+		require.NoError(t, d.MarkOutboxSent(entry.ID))
+
+		pending, err := d.FindPendingOutbox(10)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+	})
+}
+
+func TestCleanupOutbox(t *testing.T) {
+	d := newTestDB(t)
+
+	term := &db.Term{EN: "pikachu"}
+	require.NoError(t, d.CreateTerm(term))
+
+	sub := &db.Subscription{UserID: "user", TermID: term.ID}
+	sub.AddShop(sendico.Mercari)
+	require.NoError(t, d.CreateSubscription(sub))
+
+	item := sendico.Item{Shop: sendico.Mercari, Code: "m1", Name: "ピカチュウ"}
+	entry := &db.OutboxEntry{SubscriptionID: sub.ID, Items: []sendico.Item{item}, NextAttemptAt: time.Now().Add(-time.Second)}
+	require.NoError(t, d.EnqueueItems([]*db.OutboxEntry{entry}, db.Item{Shop: item.Shop, Code: item.Code, SubscriptionID: sub.ID}))
+
+	require.NoError(t, d.CleanupOutbox(time.Hour, time.Hour))
+	pending, err := d.FindPendingOutbox(10)
+	require.NoError(t, err)
+	assert.Len(t, pending, 1)
+
+	// entries that were never delivered expire too
+	require.NoError(t, d.CleanupOutbox(time.Hour, 0))
+	pending, err = d.FindPendingOutbox(10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestSellerWatch(t *testing.T) {
+	d := newTestDB(t)
+
+	term := &db.Term{EN: "Mercari seller 123456789"}
+	require.NoError(t, d.CreateTerm(term))
+
+	sellerID := "123456789"
+	sub := &db.Subscription{UserID: "user", TermID: term.ID, SellerID: &sellerID}
+	sub.AddShop(sendico.Mercari)
+	require.NoError(t, d.CreateSubscription(sub))
+
+	got, err := d.GetSubscription(sub.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &sellerID, got.SellerID)
+	assert.Equal(t, sendico.SearchOptions{Sort: sendico.SortNewest, Seller: sellerID}, got.SearchOptions(*term))
+}
+
+func TestUpdateSubscription(t *testing.T) {
+	d := newTestDB(t)
+
+	term := &db.Term{EN: "pikachu"}
+	require.NoError(t, d.CreateTerm(term))
+
+	sub := &db.Subscription{UserID: "user", TermID: term.ID}
+	sub.AddShop(sendico.Mercari)
+	require.NoError(t, d.CreateSubscription(sub))
+
+	guildID, channelID, webhookURL, sellerID := "guild", "channel", "https://discord.com/api/webhooks/1/token", "seller"
+	minPoll, maxPoll := 10*time.Minute, 2*time.Hour
+	sub.GuildID, sub.ChannelID, sub.DiscordWebhookURL, sub.SellerID = &guildID, &channelID, &webhookURL, &sellerID
+	sub.MinPollInterval, sub.MaxPollInterval = &minPoll, &maxPoll
+	require.NoError(t, d.UpdateSubscription(sub))
+
+	got, err := d.GetSubscription(sub.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &guildID, got.GuildID)
+	assert.Equal(t, &channelID, got.ChannelID)
+	assert.Equal(t, &webhookURL, got.DiscordWebhookURL)
+	assert.Equal(t, &sellerID, got.SellerID)
+	assert.Equal(t, &minPoll, got.MinPollInterval)
+	assert.Equal(t, &maxPoll, got.MaxPollInterval)
+}
@@ -46,16 +46,217 @@ table "subscriptions" {
   column "shops" {
     type = int
   }
+  column "poll_interval" {
+    type    = int
+    default = 600
+  }
+  column "poll_reason" {
+    type    = text
+    default = ""
+  }
+  column "min_poll_interval" {
+    type = int
+    null = true
+  }
+  column "max_poll_interval" {
+    type = int
+    null = true
+  }
+  column "next_poll_at" {
+    type = datetime
+    null = true
+  }
+  column "new_item_rate" {
+    type    = real
+    default = 0
+  }
+  column "paused_at" {
+    type = datetime
+    null = true
+  }
+  column "pause_reason" {
+    type = text
+    null = true
+  }
+  column "high_water_mark" {
+    type = datetime
+    null = true
+  }
+  column "notifiers" {
+    type    = text
+    default = "discord"
+  }
+  column "webhook_url" {
+    type = text
+    null = true
+  }
+  column "guild_id" {
+    type = text
+    null = true
+  }
+  column "channel_id" {
+    type = text
+    null = true
+  }
+  column "discord_webhook_url" {
+    type = text
+    null = true
+  }
+  column "thread_id" {
+    type = text
+    null = true
+  }
+  column "push_priority" {
+    type = int
+    null = true
+  }
+  column "feed_token" {
+    type = text
+    null = true
+  }
+  column "deal_threshold" {
+    type = int
+    null = true
+  }
+  column "webhook_secret" {
+    type = text
+    null = true
+  }
+  column "seller_id" {
+    type = text
+    null = true
+  }
   primary_key {
     columns = [column.id]
   }
   index "idx_last_notified_at" {
     columns = [column.last_notified_at]
   }
+  index "idx_next_poll_at" {
+    columns = [column.next_poll_at]
+  }
   index "idx_user_id_term_id" {
     columns = [column.user_id, column.term_id]
     unique = true
   }
+  index "idx_feed_token" {
+    columns = [column.feed_token]
+    unique = true
+  }
+}
+
+table "guilds" {
+  schema = schema.main
+  column "id" {
+    type = text
+  }
+  column "channel_roles" {
+    type    = text
+    default = ""
+  }
+  primary_key {
+    columns = [column.id]
+  }
+}
+
+table "emails" {
+  schema = schema.main
+  column "user_id" {
+    type = text
+  }
+  column "address" {
+    type    = text
+    default = ""
+  }
+  column "pending_address" {
+    type    = text
+    default = ""
+  }
+  column "code" {
+    type    = text
+    default = ""
+  }
+  column "code_expires_at" {
+    type = datetime
+    null = true
+  }
+  column "code_attempts" {
+    type    = int
+    default = 0
+  }
+  primary_key {
+    columns = [column.user_id]
+  }
+}
+
+table "pushes" {
+  schema = schema.main
+  column "user_id" {
+    type = text
+  }
+  column "kind" {
+    type = text
+  }
+  column "server_url" {
+    type = text
+  }
+  column "topic" {
+    type    = text
+    default = ""
+  }
+  column "token" {
+    type    = text
+    default = ""
+  }
+  primary_key {
+    columns = [column.user_id]
+  }
+}
+
+table "users" {
+  schema = schema.main
+  column "id" {
+    type = text
+  }
+  column "delivery_failures" {
+    type    = int
+    default = 0
+  }
+  column "last_failure_at" {
+    type = datetime
+    null = true
+  }
+  column "delivery_mode" {
+    type    = text
+    default = "instant"
+  }
+  column "digest_hour" {
+    type    = int
+    default = 9
+  }
+  column "timezone" {
+    type    = text
+    default = "UTC"
+  }
+  column "quiet_start" {
+    type = int
+    null = true
+  }
+  column "quiet_end" {
+    type = int
+    null = true
+  }
+  column "hour_started_at" {
+    type = datetime
+    null = true
+  }
+  column "hour_sent" {
+    type    = int
+    default = 0
+  }
+  primary_key {
+    columns = [column.id]
+  }
 }
 
 table "items" {
@@ -86,3 +287,238 @@ table "items" {
     columns = [column.created_at]
   }
 }
+
+table "seen_items" {
+  schema = schema.main
+  column "subscription_id" {
+    type = text
+  }
+  column "key" {
+    type = int
+  }
+  primary_key {
+    columns = [column.subscription_id, column.key]
+  }
+}
+
+table "matches" {
+  schema = schema.main
+  column "subscription_id" {
+    type = text
+  }
+  column "shop" {
+    type = int
+  }
+  column "code" {
+    type = text
+  }
+  column "item" {
+    type = text
+  }
+  column "matched_at" {
+    type = datetime
+  }
+  primary_key {
+    columns = [column.subscription_id, column.shop, column.code]
+  }
+  index "idx_subscription_id_matched_at" {
+    columns = [column.subscription_id, column.matched_at]
+  }
+}
+
+table "item_snapshots" {
+  schema = schema.main
+  column "shop" {
+    type = int
+  }
+  column "code" {
+    type = text
+  }
+  column "name" {
+    type = text
+  }
+  column "url" {
+    type = text
+  }
+  column "image" {
+    type = text
+  }
+  column "price_yen" {
+    type = int
+  }
+  column "price_usd" {
+    type = int
+  }
+  column "category" {
+    type = text
+    null = true
+  }
+  column "labels" {
+    type    = text
+    default = "[]"
+  }
+  column "auction" {
+    type = text
+    null = true
+  }
+  column "image_hash" {
+    type = int
+    null = true
+  }
+  column "first_seen_at" {
+    type = datetime
+  }
+  column "last_seen_at" {
+    type = datetime
+  }
+  primary_key {
+    columns = [column.shop, column.code]
+  }
+  index "idx_last_seen_at" {
+    columns = [column.last_seen_at]
+  }
+}
+
+table "term_items" {
+  schema = schema.main
+  column "term_id" {
+    type = text
+  }
+  column "shop" {
+    type = int
+  }
+  column "code" {
+    type = text
+  }
+  primary_key {
+    columns = [column.term_id, column.shop, column.code]
+  }
+  index "idx_shop_code" {
+    columns = [column.shop, column.code]
+  }
+}
+
+table "price_history" {
+  schema = schema.main
+  column "shop" {
+    type = int
+  }
+  column "code" {
+    type = text
+  }
+  column "price_yen" {
+    type = int
+  }
+  column "price_usd" {
+    type = int
+  }
+  column "seen_at" {
+    type = datetime
+  }
+  primary_key {
+    columns = [column.shop, column.code, column.seen_at]
+  }
+}
+
+table "outbox" {
+  schema = schema.main
+  column "id" {
+    type = text
+  }
+  column "subscription_id" {
+    type = text
+  }
+  column "items" {
+    type = text
+  }
+  column "attempts" {
+    type    = int
+    default = 0
+  }
+  column "next_attempt_at" {
+    type = datetime
+  }
+  column "last_error" {
+    type = text
+    null = true
+  }
+  column "created_at" {
+    type = datetime
+  }
+  column "sent_at" {
+    type = datetime
+    null = true
+  }
+  column "digest" {
+    type    = bool
+    default = false
+  }
+  column "reason" {
+    type    = text
+    default = "new"
+  }
+  column "notifier" {
+    type    = text
+    default = "discord"
+  }
+  column "notes" {
+    type    = text
+    default = "{}"
+  }
+  primary_key {
+    columns = [column.id]
+  }
+  index "idx_outbox_sent_at_next_attempt_at" {
+    columns = [column.sent_at, column.next_attempt_at]
+  }
+  index "idx_outbox_subscription_id" {
+    columns = [column.subscription_id]
+  }
+}
+
+table "feedback" {
+  schema = schema.main
+  column "subscription_id" {
+    type = text
+  }
+  column "shop" {
+    type = int
+  }
+  column "code" {
+    type = text
+  }
+  column "name" {
+    type = text
+  }
+  column "vote" {
+    type = int
+  }
+  column "voted_at" {
+    type = datetime
+  }
+  primary_key {
+    columns = [column.subscription_id, column.shop, column.code]
+  }
+}
+
+table "sellers" {
+  schema = schema.main
+  column "user_id" {
+    type = text
+  }
+  column "shop" {
+    type = int
+  }
+  column "seller_id" {
+    type = text
+  }
+  column "status" {
+    type = text
+  }
+  column "created_at" {
+    type = datetime
+  }
+  primary_key {
+    columns = [column.user_id, column.shop, column.seller_id]
+  }
+}
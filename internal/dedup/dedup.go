@@ -0,0 +1,144 @@
+package dedup
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/robherley/sendibot/pkg/sendico"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// TitleThreshold is how similar the titles of items without image hashes have to be for them to be duplicates.
+	TitleThreshold = 0.85
+	// ImageTitleThreshold is how similar the titles of items with near identical images have to be, sellers often
+	// reword titles when relisting or listing on another shop.
+	ImageTitleThreshold = 0.5
+	// MaxHashDistance is the most bits the image hashes of near identical images differ by.
+	MaxHashDistance = 8
+)
+
+// Fingerprint is what items are compared by to find duplicates.
+type Fingerprint struct {
+	// Title is the item's normalized name.
+	Title string
+	// Hash is the perceptual hash of the item's image, if HasHash is set.
+	Hash    uint64
+	HasHash bool
+}
+
+// NewFingerprint returns the fingerprint of an item's name, without an image hash.
+func NewFingerprint(name string) Fingerprint {
+	return Fingerprint{Title: Normalize(name)}
+}
+
+// WithHash returns the fingerprint with an image hash.
+func (f Fingerprint) WithHash(hash uint64) Fingerprint {
+	f.Hash = hash
+	f.HasHash = true
+	return f
+}
+
+// IsDuplicate returns whether two fingerprints are likely of the same physical item. Items with near identical images
+// only need similar titles, items without image hashes need near identical titles.
+func (f Fingerprint) IsDuplicate(other Fingerprint) bool {
+	similarity := Similarity(f.Title, other.Title)
+	if f.HasHash && other.HasHash {
+		return Distance(f.Hash, other.Hash) <= MaxHashDistance && similarity >= ImageTitleThreshold
+	}
+	return similarity >= TitleThreshold
+}
+
+// Normalize folds a name to compare it with others: full-width and half-width characters are folded to their usual
+// forms, letters are lowercased, and punctuation and symbols (often emoji or decorations like 【】) become single
+// spaces.
+func Normalize(name string) string {
+	var b strings.Builder
+	space := true
+	for _, r := range strings.ToLower(norm.NFKC.String(name)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			b.WriteRune(r)
+			space = false
+		case !space:
+			b.WriteRune(' ')
+			space = true
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// Similarity is the Dice coefficient of the character bigrams of two normalized titles, from 0 (nothing in common) to
+// 1 (the same). Bigrams work for Japanese titles, which aren't split into words.
+func Similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+
+	bigramsA, bigramsB := bigrams(a), bigrams(b)
+	if len(bigramsA) == 0 || len(bigramsB) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for bigram := range bigramsA {
+		if _, ok := bigramsB[bigram]; ok {
+			shared++
+		}
+	}
+
+	return 2 * float64(shared) / float64(len(bigramsA)+len(bigramsB))
+}
+
+func bigrams(title string) map[[2]rune]struct{} {
+	runes := []rune(strings.ReplaceAll(title, " ", ""))
+	set := make(map[[2]rune]struct{}, len(runes))
+	for i := 0; i+1 < len(runes); i++ {
+		set[[2]rune{runes[i], runes[i+1]}] = struct{}{}
+	}
+	return set
+}
+
+// Candidate is a new item and its fingerprint.
+type Candidate struct {
+	Item        sendico.Item
+	Fingerprint Fingerprint
+}
+
+// Collapse suppresses candidates that duplicate an item already seen, and groups the rest with their duplicates. Each
+// group is sorted cheapest first, groups are in the order their first item was found in.
+func Collapse(candidates []Candidate, seen []Fingerprint) (groups [][]sendico.Item, suppressed []sendico.Item) {
+	var prints [][]Fingerprint
+
+next:
+	for _, candidate := range candidates {
+		for _, print := range seen {
+			if candidate.Fingerprint.IsDuplicate(print) {
+				suppressed = append(suppressed, candidate.Item)
+				continue next
+			}
+		}
+
+		for i, group := range prints {
+			for _, print := range group {
+				if candidate.Fingerprint.IsDuplicate(print) {
+					groups[i] = append(groups[i], candidate.Item)
+					prints[i] = append(prints[i], candidate.Fingerprint)
+					continue next
+				}
+			}
+		}
+
+		groups = append(groups, []sendico.Item{candidate.Item})
+		prints = append(prints, []Fingerprint{candidate.Fingerprint})
+	}
+
+	for _, group := range groups {
+		slices.SortStableFunc(group, func(a, b sendico.Item) int {
+			return a.PriceYen - b.PriceYen
+		})
+	}
+
+	return groups, suppressed
+}
@@ -0,0 +1,83 @@
+package dedup_test
+
+import (
+	"testing"
+
+	"github.com/robherley/sendibot/internal/dedup"
+	"github.com/robherley/sendibot/pkg/sendico"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tc := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "lowercases", in: "Pokemon Card", want: "pokemon card"},
+		{name: "folds full-width", in: "ＰＳＡ１０　ピカチュウ", want: "psa10 ピカチュウ"},
+		{name: "folds half-width katakana", in: "ﾋﾟｶﾁｭｳ", want: "ピカチュウ"},
+		{name: "drops decorations", in: "【美品】 ピカチュウ ✨ 送料無料!!", want: "美品 ピカチュウ 送料無料"},
+		{name: "nothing left", in: "★☆★", want: ""},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, dedup.Normalize(tt.in))
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, dedup.Similarity("ピカチュウ", "ピカチュウ"))
+	assert.Equal(t, 0.0, dedup.Similarity("ピカチュウ", "リザードン"))
+	assert.Equal(t, 0.0, dedup.Similarity("a", "b"))
+	// spaces are ignored, the same title split differently is the same
+	assert.Equal(t, 1.0, dedup.Similarity("psa10 ピカチュウ", "psa 10ピカチュウ"))
+	assert.InDelta(t, 14.0/17.0, dedup.Similarity("ピカチュウ psa10", "ピカチュウ psa9"), 0.001)
+}
+
+func TestFingerprintIsDuplicate(t *testing.T) {
+	relisted := dedup.NewFingerprint("【美品】ピカチュウ PSA10 プロモ")
+	reworded := dedup.NewFingerprint("ピカチュウ プロモ 鑑定品")
+
+	t.Run("near identical titles", func(t *testing.T) {
+		assert.True(t, relisted.IsDuplicate(dedup.NewFingerprint("ピカチュウ PSA10 プロモ 美品")))
+		assert.False(t, relisted.IsDuplicate(reworded))
+	})
+
+	t.Run("near identical images", func(t *testing.T) {
+		assert.True(t, relisted.WithHash(0b1010).IsDuplicate(reworded.WithHash(0b1011)))
+		assert.False(t, relisted.WithHash(0).IsDuplicate(reworded.WithHash(^uint64(0))))
+	})
+
+	t.Run("same image, different item", func(t *testing.T) {
+		other := dedup.NewFingerprint("リザードン ex SAR")
+		assert.False(t, relisted.WithHash(1).IsDuplicate(other.WithHash(1)))
+	})
+}
+
+func TestCollapse(t *testing.T) {
+	item := func(shop sendico.Shop, code, name string, price int) dedup.Candidate {
+		return dedup.Candidate{
+			Item:        sendico.Item{Shop: shop, Code: code, Name: name, PriceYen: price},
+			Fingerprint: dedup.NewFingerprint(name),
+		}
+	}
+
+	mercari := item(sendico.Mercari, "m1", "ピカチュウ PSA10 プロモ", 12000)
+	rakuma := item(sendico.Rakuma, "r1", "【美品】ピカチュウ PSA10 プロモ", 11000)
+	other := item(sendico.Mercari, "m2", "リザードン ex SAR", 8000)
+	relist := item(sendico.Mercari, "m3", "ミュウツー GX 美品", 3000)
+
+	groups, suppressed := dedup.Collapse(
+		[]dedup.Candidate{mercari, other, rakuma, relist},
+		[]dedup.Fingerprint{dedup.NewFingerprint("ミュウツー GX 美品")},
+	)
+
+	assert.Equal(t, [][]sendico.Item{
+		{rakuma.Item, mercari.Item},
+		{other.Item},
+	}, groups)
+	assert.Equal(t, []sendico.Item{relist.Item}, suppressed)
+}
@@ -0,0 +1,114 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math/bits"
+	"net/http"
+	"time"
+)
+
+// MaxImageSize is the most bytes of an image that are downloaded to hash it.
+const MaxImageSize = 10 << 20
+
+// Hash is the difference hash of an image: it is shrunk to 9x8 grayscale, and each bit is whether a pixel is darker
+// than the one to its right. Resized, recompressed or slightly edited copies of an image have hashes a few bits apart.
+func Hash(img image.Image) uint64 {
+	const width, height = 9, 8
+
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return 0
+	}
+
+	var gray [height][width]float64
+	for y := range height {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(bounds.Min.Y+(y+1)*bounds.Dy()/height, y0+1)
+		for x := range width {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(bounds.Min.X+(x+1)*bounds.Dx()/width, x0+1)
+
+			var sum float64
+			for py := y0; py < y1; py++ {
+				for px := x0; px < x1; px++ {
+					r, g, b, _ := img.At(px, py).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+				}
+			}
+			gray[y][x] = sum / float64((x1-x0)*(y1-y0))
+		}
+	}
+
+	var hash uint64
+	for y := range height {
+		for x := range width - 1 {
+			if gray[y][x] < gray[y][x+1] {
+				hash |= 1 << (y*(width-1) + x)
+			}
+		}
+	}
+
+	return hash
+}
+
+// Distance is the number of bits two hashes differ by.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+type HasherOption func(*Hasher)
+
+func WithHasherHTTPClient(httpClient *http.Client) HasherOption {
+	return func(h *Hasher) {
+		h.httpClient = httpClient
+	}
+}
+
+// Hasher downloads images to hash them locally, nothing is sent anywhere else.
+type Hasher struct {
+	httpClient *http.Client
+}
+
+func NewHasher(opts ...HasherOption) *Hasher {
+	h := &Hasher{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// HashURL downloads the image at url and returns its hash.
+func (h *Hasher) HashURL(ctx context.Context, url string) (uint64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "sendibot (https://github.com/robherley/sendibot)")
+
+	res, err := h.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("image responded with status code: %d", res.StatusCode)
+	}
+
+	img, _, err := image.Decode(io.LimitReader(res.Body, MaxImageSize))
+	if err != nil {
+		return 0, err
+	}
+
+	return Hash(img), nil
+}
@@ -0,0 +1,84 @@
+package dedup_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/robherley/sendibot/internal/dedup"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gradient draws a horizontal gradient, flipped if reverse is set, with a square of noise at the given offset.
+func gradient(width, height int, reverse bool, noise int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			v := uint8(x * 255 / width)
+			if reverse {
+				v = 255 - v
+			}
+			if x >= noise && x < noise+width/10 && y < height/10 {
+				v ^= 0x40
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
+
+func TestHash(t *testing.T) {
+	original := dedup.Hash(gradient(200, 160, false, 0))
+
+	t.Run("resized", func(t *testing.T) {
+		assert.LessOrEqual(t, dedup.Distance(original, dedup.Hash(gradient(90, 72, false, 0))), dedup.MaxHashDistance)
+	})
+
+	t.Run("slightly edited", func(t *testing.T) {
+		assert.LessOrEqual(t, dedup.Distance(original, dedup.Hash(gradient(200, 160, false, 100))), dedup.MaxHashDistance)
+	})
+
+	t.Run("different", func(t *testing.T) {
+		assert.Greater(t, dedup.Distance(original, dedup.Hash(gradient(200, 160, true, 0))), dedup.MaxHashDistance)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		assert.Equal(t, uint64(0), dedup.Hash(image.NewGray(image.Rect(0, 0, 0, 0))))
+	})
+}
+
+func TestDistance(t *testing.T) {
+	assert.Equal(t, 0, dedup.Distance(0b1010, 0b1010))
+	assert.Equal(t, 2, dedup.Distance(0b1010, 0b0110))
+	assert.Equal(t, 64, dedup.Distance(0, ^uint64(0)))
+}
+
+func TestHasherHashURL(t *testing.T) {
+	img := gradient(200, 160, false, 0)
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/image.png" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	hasher := dedup.NewHasher(dedup.WithHasherHTTPClient(server.Client()))
+
+	hash, err := hasher.HashURL(context.Background(), server.URL+"/image.png")
+	require.NoError(t, err)
+	assert.Equal(t, dedup.Hash(img), hash)
+
+	_, err = hasher.HashURL(context.Background(), server.URL+"/missing.png")
+	assert.Error(t, err)
+}
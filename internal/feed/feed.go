@@ -0,0 +1,180 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/robherley/sendibot/internal/db"
+)
+
+const (
+	// ContentType is the media type feeds are served with.
+	ContentType = "application/atom+xml; charset=utf-8"
+	// Entries is the number of most recent matches a feed lists.
+	Entries = 50
+)
+
+// Server serves an Atom feed of each subscription's recent matches. Feeds are at URLs with a secret token, so only the
+// users they are shared with can find them.
+type Server struct {
+	db      db.DB
+	baseURL string
+	mux     *http.ServeMux
+}
+
+var _ http.Handler = (*Server)(nil)
+
+// New returns a server for feeds at baseURL, the public URL the server is reachable at.
+func New(db db.DB, baseURL string) *Server {
+	s := &Server{
+		db:      db,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		mux:     http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /feeds/{token}", s.handleFeed)
+
+	return s
+}
+
+// URL returns the URL of the feed with the token.
+func (s *Server) URL(token string) string {
+	return s.baseURL + "/feeds/" + token
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleFeed(w http.ResponseWriter, r *http.Request) {
+	log := slog.With("component", "feed")
+
+	sub, err := s.db.GetSubscriptionByFeedToken(r.PathValue("token"))
+	if errors.Is(err, db.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Error("failed to get subscription", "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	matches, err := s.db.GetMatches(sub.Subscription.ID, Entries)
+	if err != nil {
+		log.Error("failed to get matches", "err", err, "sub_id", sub.Subscription.ID)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	feed, err := s.feed(r, sub, matches)
+	if err != nil {
+		log.Error("failed to render feed", "err", err, "sub_id", sub.Subscription.ID)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	_, _ = w.Write(feed)
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Author   atomAuthor  `xml:"author"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Summary string      `xml:"summary"`
+	Content atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+var entryContent = template.Must(template.New("entry").Parse(
+	`{{ if .Image }}<p><a href="{{ .SendicoLink }}"><img src="{{ .Image }}" alt=""></a></p>{{ end }}` +
+		`<p>¥{{ .PriceYen }} (${{ .PriceUSD }}) on {{ .Shop.Name }}</p>` +
+		`<p><a href="{{ .SendicoLink }}">Sendico</a>{{ if .URL }} · <a href="{{ .URL }}">{{ .Shop.Name }}</a>{{ end }}</p>`,
+))
+
+func (s *Server) feed(r *http.Request, sub *db.TermSubscription, matches []db.Match) ([]byte, error) {
+	updated := sub.Subscription.LastNotifiedAt
+	if len(matches) > 0 {
+		updated = matches[0].MatchedAt
+	}
+
+	feed := atomFeed{
+		ID:       "urn:sendibot:subscription:" + sub.Subscription.ID,
+		Title:    fmt.Sprintf("sendibot: %q", sub.Term.EN),
+		Subtitle: sub.Term.JP,
+		Updated:  updated.UTC().Format(time.RFC3339),
+		Author:   atomAuthor{Name: "sendibot"},
+		Links: []atomLink{
+			{Href: s.URL(r.PathValue("token")), Rel: "self", Type: "application/atom+xml"},
+		},
+		Entries: make([]atomEntry, 0, len(matches)),
+	}
+
+	for _, match := range matches {
+		item := match.Item
+
+		var content bytes.Buffer
+		if err := entryContent.Execute(&content, &item); err != nil {
+			return nil, err
+		}
+
+		entry := atomEntry{
+			ID:      fmt.Sprintf("urn:sendibot:item:%s:%s", item.Shop.Identifier(), item.Code),
+			Title:   item.Name,
+			Updated: match.MatchedAt.UTC().Format(time.RFC3339),
+			Links: []atomLink{
+				{Href: item.SendicoLink(), Rel: "alternate", Type: "text/html"},
+			},
+			Summary: fmt.Sprintf("¥%d ($%d) on %s", item.PriceYen, item.PriceUSD, item.Shop.Name()),
+			Content: atomContent{Type: "html", Body: content.String()},
+		}
+
+		if item.Image != "" {
+			entry.Links = append(entry.Links, atomLink{Href: item.Image, Rel: "enclosure", Type: "image/jpeg"})
+		}
+
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	out, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), out...), nil
+}
@@ -0,0 +1,103 @@
+package feed_test
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/feed"
+	"github.com/robherley/sendibot/pkg/sendico"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDB struct {
+	db.DB
+	sub     *db.TermSubscription
+	matches []db.Match
+}
+
+func (f *fakeDB) GetSubscriptionByFeedToken(token string) (*db.TermSubscription, error) {
+	if f.sub == nil || f.sub.Subscription.FeedToken == nil || *f.sub.Subscription.FeedToken != token {
+		return nil, db.ErrNotFound
+	}
+	return f.sub, nil
+}
+
+func (f *fakeDB) GetMatches(subscriptionID string, limit int) ([]db.Match, error) {
+	return f.matches, nil
+}
+
+type atom struct {
+	Title   string `xml:"title"`
+	Updated string `xml:"updated"`
+	Links   []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	Entries []struct {
+		ID      string `xml:"id"`
+		Title   string `xml:"title"`
+		Summary string `xml:"summary"`
+		Content string `xml:"content"`
+		Links   []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+	} `xml:"entry"`
+}
+
+func TestFeed(t *testing.T) {
+	token := "s3cr3t"
+	matchedAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	item := sendico.Item{
+		Shop:     sendico.Mercari,
+		Code:     "m1",
+		Name:     "ゲームボーイ <SP>",
+		URL:      "https://jp.mercari.com/item/m1",
+		Image:    "https://example.com/m1.jpg",
+		PriceYen: 7800,
+		PriceUSD: 51,
+	}
+
+	fdb := &fakeDB{
+		sub: &db.TermSubscription{
+			Term:         db.Term{EN: "gameboy", JP: "ゲームボーイ"},
+			Subscription: db.Subscription{ID: "s1", FeedToken: &token},
+		},
+		matches: []db.Match{{Item: item, MatchedAt: matchedAt}},
+	}
+
+	server := feed.New(fdb, "https://sendibot.example.com/")
+
+	res := httptest.NewRecorder()
+	server.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/feeds/"+token, nil))
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, feed.ContentType, res.Header().Get("Content-Type"))
+
+	var got atom
+	require.NoError(t, xml.Unmarshal(res.Body.Bytes(), &got))
+	assert.Equal(t, `sendibot: "gameboy"`, got.Title)
+	assert.Equal(t, "2024-06-01T12:00:00Z", got.Updated)
+	assert.Equal(t, "https://sendibot.example.com/feeds/"+token, got.Links[0].Href)
+
+	require.Len(t, got.Entries, 1)
+	entry := got.Entries[0]
+	assert.Equal(t, "urn:sendibot:item:mercari:m1", entry.ID)
+	assert.Equal(t, "ゲームボーイ <SP>", entry.Title)
+	assert.Equal(t, "¥7800 ($51) on Mercari", entry.Summary)
+	assert.Contains(t, entry.Content, `<img src="https://example.com/m1.jpg"`)
+	assert.Equal(t, item.SendicoLink(), entry.Links[0].Href)
+	assert.Equal(t, "enclosure", entry.Links[1].Rel)
+}
+
+func TestFeedNotFound(t *testing.T) {
+	server := feed.New(&fakeDB{}, "https://sendibot.example.com")
+
+	res := httptest.NewRecorder()
+	server.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/feeds/nope", nil))
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
@@ -0,0 +1,90 @@
+package looper
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robherley/sendibot/internal/db"
+)
+
+const (
+	// MinPollInterval is the global floor for a subscription's poll interval, polling faster than the notify tick
+	// wouldn't do anything.
+	MinPollInterval = TickNotify
+	// MaxPollInterval is the global ceiling for a subscription's poll interval.
+	MaxPollInterval = 6 * time.Hour
+
+	// rateSmoothing is how much weight the latest observation has in the new item rate average.
+	rateSmoothing = 0.3
+	// targetItemsPerPoll is the number of new items we'd like to find on each poll.
+	targetItemsPerPoll = 1.0
+	// quietRate is the new items per hour under which a subscription is considered dead and backs off.
+	quietRate = 0.05
+)
+
+// Schedule is the outcome of a poll: when to poll next and why.
+type Schedule struct {
+	Interval time.Duration
+	Reason   string
+	Rate     float64
+}
+
+// NextSchedule picks the next poll interval for a subscription that just found newItems, based on its historical rate
+// of new items. Hot subscriptions are polled more often, dead ones back off towards the ceiling. The interval is
+// clamped to the user's floor and ceiling, then stretched if the total load (searches per hour) is over budget.
+func NextSchedule(sub *db.Subscription, newItems int, now time.Time, load float64, budget int) Schedule {
+	current := sub.PollInterval
+	if current <= 0 {
+		current = db.DefaultPollInterval
+	}
+
+	elapsed := now.Sub(sub.LastNotifiedAt)
+	if elapsed <= 0 {
+		elapsed = current
+	}
+
+	observed := float64(newItems) / elapsed.Hours()
+	rate := rateSmoothing*observed + (1-rateSmoothing)*sub.NewItemRate
+
+	schedule := Schedule{Rate: rate}
+	if rate < quietRate {
+		schedule.Interval = current * 2
+		schedule.Reason = "quiet, backing off"
+	} else {
+		schedule.Interval = time.Duration(targetItemsPerPoll / rate * float64(time.Hour))
+		schedule.Reason = fmt.Sprintf("~%.1f new/hr", rate)
+	}
+
+	floor, ceiling := pollBounds(sub)
+	if schedule.Interval < floor {
+		schedule.Interval = floor
+		schedule.Reason += ", at floor"
+	} else if schedule.Interval > ceiling {
+		schedule.Interval = ceiling
+		schedule.Reason += ", at ceiling"
+	}
+
+	if budget > 0 && load > float64(budget) {
+		schedule.Interval = time.Duration(float64(schedule.Interval) * load / float64(budget))
+		schedule.Reason += ", slowed for request budget"
+	}
+
+	schedule.Interval = schedule.Interval.Round(time.Minute)
+	return schedule
+}
+
+// pollBounds returns the floor and ceiling of the subscription's poll interval, the user's bounds can only narrow the
+// global ones.
+func pollBounds(sub *db.Subscription) (time.Duration, time.Duration) {
+	floor, ceiling := MinPollInterval, MaxPollInterval
+	if sub.MinPollInterval != nil {
+		floor = max(floor, *sub.MinPollInterval)
+	}
+	if sub.MaxPollInterval != nil {
+		ceiling = min(ceiling, *sub.MaxPollInterval)
+	}
+	if ceiling < floor {
+		ceiling = floor
+	}
+	return floor, ceiling
+}
@@ -0,0 +1,74 @@
+package looper_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/looper"
+	"github.com/stretchr/testify/assert"
+)
+
+func ptr[T any](n T) *T {
+	return &n
+}
+
+func TestNextSchedule(t *testing.T) {
+	now := time.Date(2024, 11, 14, 12, 0, 0, 0, time.UTC)
+
+	tc := []struct {
+		name     string
+		sub      db.Subscription
+		newItems int
+		load     float64
+		budget   int
+		want     time.Duration
+	}{
+		{
+			name:     "hot term polls faster",
+			sub:      db.Subscription{PollInterval: 10 * time.Minute, LastNotifiedAt: now.Add(-10 * time.Minute), NewItemRate: 6},
+			newItems: 2,
+			want:     8 * time.Minute,
+		},
+		{
+			name:     "quiet term backs off",
+			sub:      db.Subscription{PollInterval: 10 * time.Minute, LastNotifiedAt: now.Add(-10 * time.Minute)},
+			newItems: 0,
+			want:     20 * time.Minute,
+		},
+		{
+			name:     "quiet term stops at ceiling",
+			sub:      db.Subscription{PollInterval: 5 * time.Hour, LastNotifiedAt: now.Add(-5 * time.Hour)},
+			newItems: 0,
+			want:     looper.MaxPollInterval,
+		},
+		{
+			name:     "user floor",
+			sub:      db.Subscription{PollInterval: 10 * time.Minute, LastNotifiedAt: now.Add(-10 * time.Minute), NewItemRate: 60, MinPollInterval: ptr(30 * time.Minute)},
+			newItems: 10,
+			want:     30 * time.Minute,
+		},
+		{
+			name:     "user ceiling",
+			sub:      db.Subscription{PollInterval: time.Hour, LastNotifiedAt: now.Add(-time.Hour), MaxPollInterval: ptr(90 * time.Minute)},
+			newItems: 0,
+			want:     90 * time.Minute,
+		},
+		{
+			name:     "over budget",
+			sub:      db.Subscription{PollInterval: 10 * time.Minute, LastNotifiedAt: now.Add(-10 * time.Minute)},
+			newItems: 0,
+			load:     1200,
+			budget:   600,
+			want:     40 * time.Minute,
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			got := looper.NextSchedule(&tt.sub, tt.newItems, now, tt.load, tt.budget)
+			assert.Equal(t, tt.want, got.Interval)
+			assert.NotEmpty(t, got.Reason)
+		})
+	}
+}
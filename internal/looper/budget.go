@@ -0,0 +1,44 @@
+package looper
+
+import (
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket of Sendico searches, refilled at the request budget per hour. Budget a tick doesn't use is
+// carried over to the next, up to an hour's worth, so budgets smaller than a tick's share still poll every so often.
+type Bucket struct {
+	mu       sync.Mutex
+	perHour  float64
+	tokens   float64
+	refilled time.Time
+}
+
+// NewBucket returns a bucket holding a tick's share of the budget.
+func NewBucket(perHour int, now time.Time) *Bucket {
+	return &Bucket{
+		perHour:  float64(perHour),
+		tokens:   float64(perHour) * TickNotify.Hours(),
+		refilled: now,
+	}
+}
+
+// Take spends n searches if the bucket holds them. The first subscription of a tick only needs the bucket to not be
+// empty, it may go into debt that later ticks pay back, so subscriptions with more shops than a tick's share don't
+// starve.
+func (b *Bucket) Take(n int, now time.Time, first bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.refilled); elapsed > 0 {
+		b.tokens = min(b.tokens+b.perHour*elapsed.Hours(), b.perHour)
+		b.refilled = now
+	}
+
+	if b.tokens >= float64(n) || (first && b.tokens > 0) {
+		b.tokens -= float64(n)
+		return true
+	}
+
+	return false
+}
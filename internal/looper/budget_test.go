@@ -0,0 +1,53 @@
+package looper_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robherley/sendibot/internal/looper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucket(t *testing.T) {
+	now := time.Date(2024, 11, 14, 12, 0, 0, 0, time.UTC)
+
+	t.Run("spends a tick's share", func(t *testing.T) {
+		bucket := looper.NewBucket(600, now)
+		assert.True(t, bucket.Take(30, now, true))
+		assert.True(t, bucket.Take(20, now, false))
+		assert.False(t, bucket.Take(1, now, false))
+	})
+
+	t.Run("carries unused budget over", func(t *testing.T) {
+		bucket := looper.NewBucket(600, now)
+		assert.False(t, bucket.Take(80, now, false))
+		assert.True(t, bucket.Take(80, now.Add(looper.TickNotify), false))
+	})
+
+	t.Run("carries over at most an hour", func(t *testing.T) {
+		bucket := looper.NewBucket(12, now)
+		later := now.Add(24 * time.Hour)
+		assert.True(t, bucket.Take(12, later, false))
+		assert.False(t, bucket.Take(1, later, false))
+	})
+
+	t.Run("budgets under a search per tick still poll", func(t *testing.T) {
+		bucket := looper.NewBucket(6, now)
+		polls := 0
+		for tick := range 12 * 4 {
+			if bucket.Take(1, now.Add(time.Duration(tick)*looper.TickNotify), true) {
+				polls++
+			}
+		}
+		assert.Equal(t, 24, polls)
+	})
+
+	t.Run("the first subscription may go into debt", func(t *testing.T) {
+		bucket := looper.NewBucket(12, now)
+		assert.True(t, bucket.Take(5, now, true))
+		assert.False(t, bucket.Take(1, now, false))
+		// the debt of 4 searches is paid back over 4 ticks before the next one
+		assert.False(t, bucket.Take(5, now.Add(4*looper.TickNotify), true))
+		assert.True(t, bucket.Take(5, now.Add(5*looper.TickNotify), true))
+	})
+}
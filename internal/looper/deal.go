@@ -0,0 +1,69 @@
+package looper
+
+import (
+	"math"
+	"time"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/pricing"
+	"github.com/robherley/sendibot/pkg/sendico"
+)
+
+const (
+	// DealWindow is how far back the prices an item's deal score is relative to go.
+	DealWindow = 30 * 24 * time.Hour
+	// MinDealSamples is the number of prices needed to trust a median.
+	MinDealSamples = 10
+)
+
+// ScoreDeals keeps the items priced at least threshold percent below the median price of their shop. While a shop has
+// fewer than MinDealSamples prices, the median across all shops is used, and while that has too few too, items are
+// kept without a score so nothing is missed as history builds up. It returns the kept items and their notes.
+func ScoreDeals(items []sendico.Item, prices map[sendico.Shop][]int, threshold int) ([]sendico.Item, map[string]db.ItemNote) {
+	var all []int
+	for _, shopPrices := range prices {
+		all = append(all, shopPrices...)
+	}
+
+	medians := make(map[sendico.Shop]int, len(prices))
+	for shop, shopPrices := range prices {
+		if len(shopPrices) >= MinDealSamples {
+			medians[shop] = pricing.Summarize(shopPrices).Median
+		}
+	}
+
+	fallback := 0
+	if len(all) >= MinDealSamples {
+		fallback = pricing.Summarize(all).Median
+	}
+
+	deals := make([]sendico.Item, 0, len(items))
+	notes := make(map[string]db.ItemNote, len(items))
+	for _, item := range items {
+		median, ok := medians[item.Shop]
+		if !ok {
+			median = fallback
+		}
+
+		if median <= 0 {
+			deals = append(deals, item)
+			notes[db.NoteKey(item)] = db.ItemNote{Deal: &db.Deal{}}
+			continue
+		}
+
+		score := DealScore(item.PriceYen, median)
+		if score < threshold {
+			continue
+		}
+
+		deals = append(deals, item)
+		notes[db.NoteKey(item)] = db.ItemNote{Deal: &db.Deal{Score: &score, MedianYen: median}}
+	}
+
+	return deals, notes
+}
+
+// DealScore is how far below the median a price is, in percent, negative if it is above.
+func DealScore(price, median int) int {
+	return int(math.Round(float64(median-price) / float64(median) * 100))
+}
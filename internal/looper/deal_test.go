@@ -0,0 +1,71 @@
+package looper_test
+
+import (
+	"testing"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/looper"
+	"github.com/robherley/sendibot/pkg/sendico"
+	"github.com/stretchr/testify/assert"
+)
+
+func priced(shop sendico.Shop, code string, price int) sendico.Item {
+	return sendico.Item{Shop: shop, Code: code, PriceYen: price}
+}
+
+// around returns n prices with the given median.
+func around(median, n int) []int {
+	prices := make([]int, 0, n)
+	for i := range n {
+		prices = append(prices, median-n/2*100+i*100)
+	}
+	return prices
+}
+
+func TestDealScore(t *testing.T) {
+	assert.Equal(t, 25, looper.DealScore(7500, 10000))
+	assert.Equal(t, 0, looper.DealScore(10000, 10000))
+	assert.Equal(t, -50, looper.DealScore(15000, 10000))
+	assert.Equal(t, 33, looper.DealScore(2000, 3000))
+}
+
+func TestScoreDeals(t *testing.T) {
+	cheap := priced(sendico.Mercari, "m1", 7000)
+	usual := priced(sendico.Mercari, "m2", 9900)
+	rakuma := priced(sendico.Rakuma, "r1", 7000)
+
+	t.Run("keeps items below the threshold", func(t *testing.T) {
+		prices := map[sendico.Shop][]int{sendico.Mercari: around(10000, 11)}
+
+		deals, notes := looper.ScoreDeals([]sendico.Item{cheap, usual}, prices, 20)
+		assert.Equal(t, []sendico.Item{cheap}, deals)
+		assert.Equal(t, map[string]db.ItemNote{
+			db.NoteKey(cheap): {Deal: &db.Deal{Score: ptr(30), MedianYen: 10000}},
+		}, notes)
+	})
+
+	t.Run("falls back to the median across shops", func(t *testing.T) {
+		prices := map[sendico.Shop][]int{
+			sendico.Mercari: around(10000, 11),
+			sendico.Rakuma:  {4000},
+		}
+		pricey := priced(sendico.Rakuma, "r2", 9000)
+
+		deals, notes := looper.ScoreDeals([]sendico.Item{rakuma, pricey}, prices, 20)
+		assert.Equal(t, []sendico.Item{rakuma}, deals)
+		assert.Equal(t, map[string]db.ItemNote{
+			db.NoteKey(rakuma): {Deal: &db.Deal{Score: ptr(30), MedianYen: 9950}},
+		}, notes)
+	})
+
+	t.Run("keeps everything without enough history", func(t *testing.T) {
+		prices := map[sendico.Shop][]int{sendico.Mercari: {10000, 12000}}
+
+		deals, notes := looper.ScoreDeals([]sendico.Item{cheap, usual}, prices, 20)
+		assert.Equal(t, []sendico.Item{cheap, usual}, deals)
+		assert.Equal(t, map[string]db.ItemNote{
+			db.NoteKey(cheap): {Deal: &db.Deal{}},
+			db.NoteKey(usual): {Deal: &db.Deal{}},
+		}, notes)
+	})
+}
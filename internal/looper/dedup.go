@@ -0,0 +1,103 @@
+package looper
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/dedup"
+	"github.com/robherley/sendibot/pkg/sendico"
+)
+
+// DedupLookback is the number of a subscription's latest matches new items are checked against for duplicates.
+const DedupLookback = 200
+
+// Dedup collapses new items that are listings of the same physical item, e.g. on several shops or relisted. The
+// cheapest of each group of duplicates is kept, and the rest are noted as its variants. Items that duplicate one of the
+// subscription's past matches are dropped, they were already notified on. Images are hashed if the looper has a hasher,
+// otherwise items are only compared by their titles. It returns the kept items and their notes.
+func (l *Looper) Dedup(ctx context.Context, sub *db.Subscription, items []sendico.Item) ([]sendico.Item, map[string]db.ItemNote) {
+	log := slog.With("component", "looper.notify", "sub_id", sub.ID)
+
+	matches, err := l.db.GetMatches(sub.ID, DedupLookback)
+	if err != nil {
+		// better to notify on a duplicate than miss an item
+		log.Error("failed to get matches to dedup against", "err", err)
+	}
+
+	seenItems := make([]sendico.Item, 0, len(matches))
+	for _, match := range matches {
+		seenItems = append(seenItems, match.Item)
+	}
+
+	hashes := l.imageHashes(ctx, items, seenItems)
+
+	candidates := make([]dedup.Candidate, 0, len(items))
+	for _, item := range items {
+		candidates = append(candidates, dedup.Candidate{Item: item, Fingerprint: fingerprint(item, hashes)})
+	}
+
+	seen := make([]dedup.Fingerprint, 0, len(seenItems))
+	for _, item := range seenItems {
+		seen = append(seen, fingerprint(item, hashes))
+	}
+
+	groups, suppressed := dedup.Collapse(candidates, seen)
+	if len(suppressed) > 0 {
+		log.Info("suppressed duplicates of past matches", "count", len(suppressed))
+	}
+
+	kept := make([]sendico.Item, 0, len(groups))
+	notes := make(map[string]db.ItemNote)
+	for _, group := range groups {
+		kept = append(kept, group[0])
+		if len(group) > 1 {
+			notes[db.NoteKey(group[0])] = db.ItemNote{Variants: group[1:]}
+		}
+	}
+
+	return kept, notes
+}
+
+// imageHashes returns the image hashes of new and seen items, keyed by db.NoteKey. New items that haven't been hashed
+// yet are hashed and stored, seen items only use stored hashes.
+func (l *Looper) imageHashes(ctx context.Context, items, seenItems []sendico.Item) map[string]uint64 {
+	log := slog.With("component", "looper.notify")
+
+	hashes, err := l.db.GetImageHashes(append(items[:len(items):len(items)], seenItems...)...)
+	if err != nil {
+		log.Error("failed to get image hashes", "err", err)
+		hashes = make(map[string]uint64)
+	}
+
+	if l.hasher == nil {
+		return hashes
+	}
+
+	for _, item := range items {
+		if _, ok := hashes[db.NoteKey(item)]; ok || item.Image == "" {
+			continue
+		}
+
+		hash, err := l.hasher.HashURL(ctx, item.Image)
+		if err != nil {
+			log.Warn("failed to hash image", "err", err, "item", db.NoteKey(item))
+			continue
+		}
+		hashes[db.NoteKey(item)] = hash
+
+		if err := l.db.SaveImageHash(item, hash); err != nil {
+			log.Error("failed to save image hash", "err", err, "item", db.NoteKey(item))
+		}
+	}
+
+	return hashes
+}
+
+func fingerprint(item sendico.Item, hashes map[string]uint64) dedup.Fingerprint {
+	print := dedup.NewFingerprint(item.Name)
+	if hash, ok := hashes[db.NoteKey(item)]; ok {
+		print = print.WithHash(hash)
+	}
+	return print
+}
@@ -0,0 +1,47 @@
+package looper
+
+import (
+	"time"
+
+	"github.com/robherley/sendibot/internal/db"
+)
+
+// DeliverAt returns when items found at now should be delivered to the user, and whether they should be held for a
+// digest. Hourly digests go out at the top of the next local hour, daily digests at the user's digest hour, and
+// anything that would land in quiet hours is held until they end.
+func DeliverAt(user *db.User, now time.Time) (time.Time, bool) {
+	loc := user.Location()
+	local := now.In(loc)
+
+	at, digest := now, false
+	switch user.DeliveryMode {
+	case db.DeliveryHourly:
+		at = time.Date(local.Year(), local.Month(), local.Day(), local.Hour()+1, 0, 0, 0, loc)
+		digest = true
+	case db.DeliveryDaily:
+		at = time.Date(local.Year(), local.Month(), local.Day(), user.DigestHour, 0, 0, 0, loc)
+		if !at.After(local) {
+			at = at.AddDate(0, 0, 1)
+		}
+		digest = true
+	}
+
+	if user.IsQuiet(at) {
+		at = quietEnd(user, at)
+		digest = true
+	}
+
+	return at, digest
+}
+
+// quietEnd returns the end of the quiet hours that t falls in.
+func quietEnd(user *db.User, t time.Time) time.Time {
+	loc := user.Location()
+	local := t.In(loc)
+
+	end := time.Date(local.Year(), local.Month(), local.Day(), *user.QuietEnd, 0, 0, 0, loc)
+	if !end.After(local) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
@@ -0,0 +1,70 @@
+package looper_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/looper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliverAt(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip("no tzdata")
+	}
+
+	// 23:30 in Tokyo
+	now := time.Date(2024, 11, 14, 14, 30, 0, 0, time.UTC)
+
+	tc := []struct {
+		name       string
+		user       db.User
+		want       time.Time
+		wantDigest bool
+	}{
+		{
+			name: "instant",
+			user: db.User{DeliveryMode: db.DeliveryInstant, Timezone: "Asia/Tokyo"},
+			want: now,
+		},
+		{
+			name:       "hourly",
+			user:       db.User{DeliveryMode: db.DeliveryHourly, Timezone: "Asia/Tokyo"},
+			want:       time.Date(2024, 11, 15, 0, 0, 0, 0, tokyo),
+			wantDigest: true,
+		},
+		{
+			name:       "daily later today",
+			user:       db.User{DeliveryMode: db.DeliveryDaily, DigestHour: 23, Timezone: "UTC"},
+			want:       time.Date(2024, 11, 14, 23, 0, 0, 0, time.UTC),
+			wantDigest: true,
+		},
+		{
+			name:       "daily tomorrow",
+			user:       db.User{DeliveryMode: db.DeliveryDaily, DigestHour: 9, Timezone: "Asia/Tokyo"},
+			want:       time.Date(2024, 11, 15, 9, 0, 0, 0, tokyo),
+			wantDigest: true,
+		},
+		{
+			name:       "instant during quiet hours",
+			user:       db.User{DeliveryMode: db.DeliveryInstant, Timezone: "Asia/Tokyo", QuietStart: ptr(22), QuietEnd: ptr(7)},
+			want:       time.Date(2024, 11, 15, 7, 0, 0, 0, tokyo),
+			wantDigest: true,
+		},
+		{
+			name: "instant outside quiet hours",
+			user: db.User{DeliveryMode: db.DeliveryInstant, Timezone: "Asia/Tokyo", QuietStart: ptr(1), QuietEnd: ptr(7)},
+			want: now,
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			got, digest := looper.DeliverAt(&tt.user, now)
+			assert.True(t, tt.want.Equal(got), "want %s, got %s", tt.want, got)
+			assert.Equal(t, tt.wantDigest, digest)
+		})
+	}
+}
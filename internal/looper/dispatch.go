@@ -0,0 +1,331 @@
+package looper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/notify"
+)
+
+const (
+	// DispatchBackoff is the delay before the first retry of a failed delivery, it doubles on every attempt.
+	DispatchBackoff = 30 * time.Second
+	// MaxDispatchBackoff caps the delay between delivery retries.
+	MaxDispatchBackoff = 1 * time.Hour
+	// MaxDispatchAttempts is the number of failed deliveries, about a day's worth, after which an entry's subscription
+	// is paused. The entry is kept until the user resumes the subscription or it expires, see WindowUndelivered.
+	MaxDispatchAttempts = 30
+	// MaxDeliveryFailures is the number of consecutive unreachable errors after which a user's subscriptions are paused.
+	MaxDeliveryFailures = 3
+)
+
+// Dispatch delivers pending outbox entries, rescheduling the ones that fail. Due entries are batched per user and
+// notifier: instant entries go out as one notification, held entries as one digest and entries from catching up as one
+// summary.
+func (l *Looper) Dispatch(ctx context.Context) error {
+	log := slog.With("component", "looper.dispatch")
+
+	if l.notifying.Load() {
+		log.Debug("notify is running, waiting for it to finish")
+		return nil
+	}
+
+	entries, err := l.db.FindPendingOutbox(100)
+	if err != nil {
+		return fmt.Errorf("failed to find pending outbox: %w", err)
+	}
+
+	type batchKey struct {
+		userID   string
+		notifier string
+	}
+
+	type userBatch struct {
+		instant []db.OutboxEntry
+		digest  []db.OutboxEntry
+		catchUp []db.OutboxEntry
+	}
+
+	var keys []batchKey
+	batches := make(map[batchKey]*userBatch)
+	for _, entry := range entries {
+		key := batchKey{entry.Subscription.UserID, entry.Notifier}
+		batch, ok := batches[key]
+		if !ok {
+			batch = &userBatch{}
+			batches[key] = batch
+			keys = append(keys, key)
+		}
+
+		switch {
+		case entry.Reason == db.OutboxReasonCatchUp:
+			batch.catchUp = append(batch.catchUp, entry)
+		case entry.Digest:
+			batch.digest = append(batch.digest, entry)
+		default:
+			batch.instant = append(batch.instant, entry)
+		}
+	}
+
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			log.Info("context done, stopping early")
+			return nil
+		}
+
+		notifier, ok := l.notifiers[key.notifier]
+		batch := batches[key]
+		if !ok {
+			// retrying won't register the notifier, hold the subscriptions until the user resumes them
+			err := notify.NewUnknownNotifierError(key.notifier)
+			entries := slices.Concat(batch.instant, batch.digest, batch.catchUp)
+			l.delivered(key.userID, err, entries...)
+			l.pauseSubscriptions(db.PauseReasonNotifierDisabled, entries...)
+			continue
+		}
+
+		// finish the batch even if we are shutting down, so it isn't sent twice
+		notifyCtx := context.WithoutCancel(ctx)
+		if len(batch.instant) > 0 {
+			l.dispatchInstant(notifyCtx, notifier, key.userID, batch.instant)
+		}
+		if len(batch.digest) > 0 {
+			err := notifier.Notify(notifyCtx, events(notify.ReasonDigest, batch.digest)...)
+			l.delivered(key.userID, err, batch.digest...)
+		}
+		if len(batch.catchUp) > 0 {
+			err := notifier.Notify(notifyCtx, events(notify.ReasonCatchUp, batch.catchUp)...)
+			l.delivered(key.userID, err, batch.catchUp...)
+		}
+	}
+
+	return nil
+}
+
+// dispatchInstant sends a user's instant entries as one notification, within their hourly cap. The cap counts items
+// across all of the user's notifiers.
+func (l *Looper) dispatchInstant(ctx context.Context, notifier notify.Notifier, userID string, entries []db.OutboxEntry) {
+	user, err := l.db.GetUser(userID)
+	if err != nil {
+		slog.Error("failed to get user", "err", err, "component", "looper.dispatch", "user_id", userID)
+		return
+	}
+
+	now := time.Now().UTC()
+	toSend := make([]db.OutboxEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !l.applyHourlyCap(user, &entry) {
+			continue
+		}
+
+		// count what's about to be sent so the rest of the batch sees it
+		if user.SentThisHour(now) == 0 {
+			user.HourStartedAt = &now
+			user.HourSent = 0
+		}
+		user.HourSent += len(entry.Items)
+
+		toSend = append(toSend, entry)
+	}
+
+	if len(toSend) == 0 {
+		return
+	}
+
+	err = notifier.Notify(ctx, events(notify.ReasonNew, toSend)...)
+	l.delivered(userID, err, toSend...)
+}
+
+func events(reason notify.Reason, entries []db.OutboxEntry) []notify.Event {
+	events := make([]notify.Event, 0, len(entries))
+	for _, entry := range entries {
+		events = append(events, notify.Event{
+			Subscription: entry.Subscription,
+			Term:         entry.Term,
+			Items:        entry.Items,
+			Notes:        entry.Notes,
+			Reason:       reason,
+		})
+	}
+	return events
+}
+
+// applyHourlyCap trims an instant entry down to what the user has left of their hourly cap, the rest is spilled into a
+// digest that goes out when the hour is over. It returns false if there is nothing left to send now.
+func (l *Looper) applyHourlyCap(user *db.User, entry *db.OutboxEntry) bool {
+	if l.hourlyCap <= 0 {
+		return true
+	}
+
+	now := time.Now().UTC()
+	remaining := max(l.hourlyCap-user.SentThisHour(now), 0)
+	if len(entry.Items) <= remaining {
+		return true
+	}
+
+	keep, spill := entry.Items[:remaining], entry.Items[remaining:]
+	digest := &db.OutboxEntry{
+		SubscriptionID: entry.SubscriptionID,
+		Items:          spill,
+		NextAttemptAt:  user.NextHour(now),
+		Digest:         true,
+		Notifier:       entry.Notifier,
+		Notes:          entry.Notes,
+	}
+
+	if err := l.db.SplitOutbox(entry.ID, keep, digest); err != nil {
+		slog.Error("failed to spill items into digest", "err", err, "outbox_id", entry.ID, "user_id", user.ID)
+		return false
+	}
+
+	slog.Info("hourly cap reached, spilled items into digest", "outbox_id", entry.ID, "user_id", user.ID, "spilled", len(spill), "at", digest.NextAttemptAt)
+	entry.Items = keep
+	return len(keep) > 0
+}
+
+// delivered records the outcome of delivering entries to a user. Entries are sent as one event each, so a partial
+// delivery marks the entries that went out as sent and only retries the rest.
+func (l *Looper) delivered(userID string, err error, entries ...db.OutboxEntry) {
+	log := slog.With("component", "looper.dispatch", "user_id", userID)
+
+	var partial *notify.PartialError
+	if errors.As(err, &partial) {
+		var (
+			sent   []db.OutboxEntry
+			errs   []error
+			failed = make(map[error][]db.OutboxEntry)
+		)
+		for i, entry := range entries {
+			if slices.Contains(partial.Sent, i) {
+				sent = append(sent, entry)
+				continue
+			}
+
+			err := partial.Err
+			if failedErr, ok := partial.Failed[i]; ok {
+				err = failedErr
+			}
+			if _, ok := failed[err]; !ok {
+				errs = append(errs, err)
+			}
+			failed[err] = append(failed[err], entry)
+		}
+
+		l.delivered(userID, nil, sent...)
+		for _, err := range errs {
+			l.delivered(userID, err, failed[err]...)
+		}
+		return
+	}
+
+	if len(entries) == 0 {
+		return
+	}
+
+	if err != nil {
+		for _, entry := range entries {
+			next := time.Now().Add(dispatchBackoff(entry.Attempts))
+			log.Error("failed to deliver, will retry", "err", err, "outbox_id", entry.ID, "attempts", entry.Attempts+1, "next_attempt_at", next)
+
+			if err := l.db.MarkOutboxFailed(entry.ID, err.Error(), next); err != nil {
+				log.Error("failed to mark outbox entry failed", "err", err, "outbox_id", entry.ID)
+			}
+		}
+
+		if errors.Is(err, notify.ErrUnreachable) && len(entries) > 0 {
+			if entries[0].Notifier == db.DefaultNotifier {
+				l.recordUnreachable(userID)
+			} else {
+				// the user may still be around, it is only this destination that is gone
+				l.pauseSubscriptions(db.PauseReasonTargetGone, entries...)
+			}
+		}
+		if errors.Is(err, notify.ErrRejected) {
+			l.pauseSubscriptions(db.PauseReasonRejected, entries...)
+		}
+
+		exhausted := slices.DeleteFunc(slices.Clone(entries), func(entry db.OutboxEntry) bool {
+			return entry.Attempts+1 < MaxDispatchAttempts
+		})
+		l.pauseSubscriptions(db.PauseReasonUndeliverable, exhausted...)
+		return
+	}
+
+	count, instant := 0, 0
+	for _, entry := range entries {
+		if err := l.db.MarkOutboxSent(entry.ID); err != nil {
+			log.Error("failed to mark outbox entry sent", "err", err, "outbox_id", entry.ID)
+		}
+		count += len(entry.Items)
+		if !entry.Digest {
+			instant += len(entry.Items)
+		}
+	}
+
+	if len(entries) > 0 && entries[0].Notifier == db.DefaultNotifier {
+		if err := l.db.ResetDeliveryFailures(userID); err != nil {
+			log.Error("failed to reset delivery failures", "err", err)
+		}
+	}
+
+	// digests are where capped items go, so only instant items count towards the cap
+	if instant > 0 {
+		if err := l.db.CountSent(userID, instant); err != nil {
+			log.Error("failed to count sent items", "err", err)
+		}
+	}
+
+	log.Info("delivered", "entries", len(entries), "count", count)
+}
+
+// recordUnreachable counts a permanent delivery failure for the user, pausing their subscriptions once there have been
+// too many in a row. They are resumed the next time the user interacts with the bot.
+func (l *Looper) recordUnreachable(userID string) {
+	log := slog.With("component", "looper.dispatch", "user_id", userID)
+
+	failures, err := l.db.RecordDeliveryFailure(userID)
+	if err != nil {
+		log.Error("failed to record delivery failure", "err", err)
+		return
+	}
+
+	if failures < MaxDeliveryFailures {
+		return
+	}
+
+	if err := l.db.PauseUserSubscriptions(userID, db.PauseReasonUnreachable); err != nil {
+		log.Error("failed to pause subscriptions", "err", err)
+		return
+	}
+
+	log.Warn("paused subscriptions for unreachable user", "failures", failures)
+}
+
+// pauseSubscriptions pauses the subscriptions of entries that can't be delivered, because their channel or webhook no
+// longer exists, their notifier isn't configured, they were refused or they kept failing. They stay paused until the user resumes or
+// subscribes again.
+func (l *Looper) pauseSubscriptions(reason db.PauseReason, entries ...db.OutboxEntry) {
+	paused := make(map[string]bool)
+	for _, entry := range entries {
+		if paused[entry.SubscriptionID] {
+			continue
+		}
+		paused[entry.SubscriptionID] = true
+
+		if err := l.db.PauseSubscription(entry.SubscriptionID, reason); err != nil {
+			slog.Error("failed to pause subscription", "err", err, "component", "looper.dispatch", "sub_id", entry.SubscriptionID)
+			continue
+		}
+
+		slog.Warn("paused subscription, it can't be delivered", "component", "looper.dispatch", "sub_id", entry.SubscriptionID, "notifier", entry.Notifier, "reason", reason)
+	}
+}
+
+func dispatchBackoff(attempts int) time.Duration {
+	return min(DispatchBackoff<<min(attempts, 16), MaxDispatchBackoff)
+}
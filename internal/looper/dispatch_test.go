@@ -0,0 +1,183 @@
+package looper_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/looper"
+	"github.com/robherley/sendibot/internal/notify"
+	"github.com/robherley/sendibot/pkg/sendico"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDB implements the parts of db.DB that Dispatch uses, anything else panics.
+type fakeDB struct {
+	db.DB
+	pending []db.OutboxEntry
+	sent    []string
+	failed  []string
+	paused  []string
+}
+
+func (f *fakeDB) FindPendingOutbox(int) ([]db.OutboxEntry, error) { return f.pending, nil }
+func (f *fakeDB) GetUser(id string) (*db.User, error)             { return &db.User{ID: id}, nil }
+func (f *fakeDB) MarkOutboxSent(id string) error                  { f.sent = append(f.sent, id); return nil }
+func (f *fakeDB) ResetDeliveryFailures(string) error              { return nil }
+func (f *fakeDB) CountSent(string, int) error                     { return nil }
+func (f *fakeDB) RecordDeliveryFailure(string) (int, error)       { return 1, nil }
+
+func (f *fakeDB) PauseSubscription(id string, _ db.PauseReason) error {
+	f.paused = append(f.paused, id)
+	return nil
+}
+
+func (f *fakeDB) MarkOutboxFailed(id string, _ string, _ time.Time) error {
+	f.failed = append(f.failed, id)
+	return nil
+}
+
+type fakeNotifier struct {
+	name   string
+	err    error
+	events [][]notify.Event
+}
+
+func (f *fakeNotifier) Name() string { return f.name }
+
+func (f *fakeNotifier) Notify(_ context.Context, events ...notify.Event) error {
+	f.events = append(f.events, events)
+	return f.err
+}
+
+func entry(id, userID, notifier string, reason db.OutboxReason, digest bool) db.OutboxEntry {
+	return db.OutboxEntry{
+		ID:           id,
+		Items:        []sendico.Item{{Shop: sendico.Mercari, Code: id}},
+		Digest:       digest,
+		Reason:       reason,
+		Notifier:     notifier,
+		Term:         db.Term{EN: "gameboy"},
+		Subscription: db.Subscription{UserID: userID},
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	fdb := &fakeDB{
+		pending: []db.OutboxEntry{
+			entry("o1", "u1", "discord", db.OutboxReasonNew, false),
+			entry("o2", "u1", "discord", db.OutboxReasonNew, false),
+			entry("o3", "u1", "webhook", db.OutboxReasonNew, false),
+			entry("o4", "u1", "discord", db.OutboxReasonNew, true),
+			entry("o5", "u2", "discord", db.OutboxReasonCatchUp, false),
+			entry("o6", "u2", "missing", db.OutboxReasonNew, false),
+		},
+	}
+	fdb.pending[5].SubscriptionID = "s6"
+	discord := &fakeNotifier{name: "discord"}
+	webhook := &fakeNotifier{name: "webhook", err: errors.New("boom")}
+
+	l := looper.New(fdb, nil, looper.WithNotifier(discord), looper.WithNotifier(webhook))
+	assert.NoError(t, l.Dispatch(context.Background()))
+
+	if assert.Len(t, discord.events, 3) {
+		// instant entries of a user are sent together
+		assert.Len(t, discord.events[0], 2)
+		assert.Equal(t, notify.ReasonNew, discord.events[0][0].Reason)
+		assert.Equal(t, "gameboy", discord.events[0][0].Term.EN)
+		assert.Equal(t, notify.ReasonDigest, discord.events[1][0].Reason)
+		assert.Equal(t, notify.ReasonCatchUp, discord.events[2][0].Reason)
+		assert.Equal(t, "u2", discord.events[2][0].Subscription.UserID)
+	}
+	assert.Len(t, webhook.events, 1)
+
+	assert.ElementsMatch(t, []string{"o1", "o2", "o4", "o5"}, fdb.sent)
+	assert.ElementsMatch(t, []string{"o3", "o6"}, fdb.failed)
+	// retrying won't register the missing notifier
+	assert.Equal(t, []string{"s6"}, fdb.paused)
+}
+
+func TestDispatchTargetGone(t *testing.T) {
+	gone := entry("o1", "u1", "channel", db.OutboxReasonNew, false)
+	gone.SubscriptionID = "s1"
+	fdb := &fakeDB{pending: []db.OutboxEntry{gone}}
+	channel := &fakeNotifier{name: "channel", err: notify.NewUnreachableError(errors.New("unknown channel"))}
+
+	l := looper.New(fdb, nil, looper.WithNotifier(channel))
+	assert.NoError(t, l.Dispatch(context.Background()))
+
+	// only the subscription is paused, the user can still be messaged
+	assert.Equal(t, []string{"o1"}, fdb.failed)
+	assert.Equal(t, []string{"s1"}, fdb.paused)
+}
+
+func TestDispatchPartial(t *testing.T) {
+	fdb := &fakeDB{
+		pending: []db.OutboxEntry{
+			entry("o1", "u1", "webhook", db.OutboxReasonNew, false),
+			entry("o2", "u1", "webhook", db.OutboxReasonNew, false),
+			entry("o3", "u1", "webhook", db.OutboxReasonNew, false),
+		},
+	}
+	webhook := &fakeNotifier{name: "webhook", err: notify.NewPartialError([]int{1}, errors.New("boom"))}
+
+	l := looper.New(fdb, nil, looper.WithNotifier(webhook))
+	assert.NoError(t, l.Dispatch(context.Background()))
+
+	// only what wasn't sent is retried
+	assert.Equal(t, []string{"o2"}, fdb.sent)
+	assert.Equal(t, []string{"o1", "o3"}, fdb.failed)
+}
+
+func TestDispatchPartialTargetGone(t *testing.T) {
+	fine := entry("o1", "u1", "channel", db.OutboxReasonNew, false)
+	fine.SubscriptionID = "s1"
+	gone := entry("o2", "u1", "channel", db.OutboxReasonNew, false)
+	gone.SubscriptionID = "s2"
+	fdb := &fakeDB{pending: []db.OutboxEntry{fine, gone}}
+	channel := &fakeNotifier{name: "channel", err: &notify.PartialError{
+		Sent:   []int{0},
+		Err:    errors.New("boom"),
+		Failed: map[int]error{1: notify.NewUnreachableError(errors.New("no destination"))},
+	}}
+
+	l := looper.New(fdb, nil, looper.WithNotifier(channel))
+	assert.NoError(t, l.Dispatch(context.Background()))
+
+	// only the subscription that lost its destination is paused
+	assert.Equal(t, []string{"o1"}, fdb.sent)
+	assert.Equal(t, []string{"o2"}, fdb.failed)
+	assert.Equal(t, []string{"s2"}, fdb.paused)
+}
+
+func TestDispatchExhausted(t *testing.T) {
+	retried := entry("o1", "u1", "webhook", db.OutboxReasonNew, false)
+	retried.SubscriptionID = "s1"
+	exhausted := entry("o2", "u1", "webhook", db.OutboxReasonNew, false)
+	exhausted.SubscriptionID = "s2"
+	exhausted.Attempts = looper.MaxDispatchAttempts - 1
+	fdb := &fakeDB{pending: []db.OutboxEntry{retried, exhausted}}
+	webhook := &fakeNotifier{name: "webhook", err: errors.New("boom")}
+
+	l := looper.New(fdb, nil, looper.WithNotifier(webhook))
+	assert.NoError(t, l.Dispatch(context.Background()))
+
+	// both are kept for later, but the one out of attempts holds its subscription until the user resumes it
+	assert.Equal(t, []string{"o1", "o2"}, fdb.failed)
+	assert.Equal(t, []string{"s2"}, fdb.paused)
+}
+
+func TestDispatchRejected(t *testing.T) {
+	refused := entry("o1", "u1", "webhook", db.OutboxReasonNew, false)
+	refused.SubscriptionID = "s1"
+	fdb := &fakeDB{pending: []db.OutboxEntry{refused}}
+	webhook := &fakeNotifier{name: "webhook", err: notify.NewRejectedError(errors.New("forbidden"))}
+
+	l := looper.New(fdb, nil, looper.WithNotifier(webhook))
+	assert.NoError(t, l.Dispatch(context.Background()))
+
+	assert.Equal(t, []string{"o1"}, fdb.failed)
+	assert.Equal(t, []string{"s1"}, fdb.paused)
+}
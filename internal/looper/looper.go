@@ -2,160 +2,387 @@ package looper
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"sync/atomic"
 	"time"
 
-	"github.com/robherley/sendibot/internal/bot"
 	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/dedup"
+	"github.com/robherley/sendibot/internal/notify"
+	"github.com/robherley/sendibot/internal/relevance"
 	"github.com/robherley/sendibot/pkg/sendico"
 )
 
 const (
-	TickNotify  = 5 * time.Minute
-	TickCleanup = 1 * time.Hour
-	TickRefresh = 30 * time.Minute
+	TickNotify   = 5 * time.Minute
+	TickDispatch = 30 * time.Second
+	TickCleanup  = 1 * time.Hour
+	TickRefresh  = 30 * time.Minute
 
-	WindowNotify  = 10 * time.Minute
 	WindowCleanup = 72 * time.Hour
+	// WindowUndelivered is how long an outbox entry that couldn't be delivered is kept, e.g. while its subscription is
+	// paused.
+	WindowUndelivered = 7 * 24 * time.Hour
+	// WindowSnapshots is how long an item that stopped showing up in searches keeps its snapshot and price history.
+	WindowSnapshots = 180 * 24 * time.Hour
+	// WindowMatches is how long the items a subscription alerted on are kept for its feed and /history.
+	WindowMatches = 90 * 24 * time.Hour
+
+	// CatchUpThreshold is how long the bot has to have been offline past when a subscription was due for its new items
+	// to be summarized instead of sent one by one.
+	CatchUpThreshold = 1 * time.Hour
+
+	JobNotify   = "notify"
+	JobDispatch = "dispatch"
+	JobCleanup  = "cleanup"
+	JobRefresh  = "refresh"
 )
 
+type Option func(*Looper)
+
+// WithRequestBudget caps the number of Sendico searches per hour across all subscriptions, 0 is unlimited.
+func WithRequestBudget(budget int) Option {
+	return func(l *Looper) {
+		l.budget = budget
+	}
+}
+
+// WithHourlyItemCap caps the number of items sent to a user per hour, the excess is spilled into a digest. 0 is
+// unlimited.
+func WithHourlyItemCap(n int) Option {
+	return func(l *Looper) {
+		l.hourlyCap = n
+	}
+}
+
+// WithHasher hashes item images to find duplicates by how they look, not just by their titles.
+func WithHasher(hasher *dedup.Hasher) Option {
+	return func(l *Looper) {
+		l.hasher = hasher
+	}
+}
+
+// WithNotifier registers a notifier that subscriptions can deliver through, by its name.
+func WithNotifier(n notify.Notifier) Option {
+	return func(l *Looper) {
+		l.notifiers[n.Name()] = n
+	}
+}
+
 type Looper struct {
-	db      db.DB
-	sendico *sendico.Client
-	bot     *bot.Bot
+	db        db.DB
+	sendico   *sendico.Client
+	notifiers map[string]notify.Notifier
+	hasher    *dedup.Hasher
+	budget    int
+	bucket    *Bucket
+	started   time.Time
+	hourlyCap int
+	scheduler *Scheduler
+	notifying atomic.Bool
+}
+
+func New(db db.DB, sendico *sendico.Client, opts ...Option) *Looper {
+	l := &Looper{db: db, sendico: sendico, notifiers: make(map[string]notify.Notifier), started: time.Now()}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	if l.budget > 0 {
+		l.bucket = NewBucket(l.budget, time.Now())
+	}
+
+	l.scheduler = NewScheduler(
+		Job{Name: JobNotify, Interval: TickNotify, Jitter: 15 * time.Second, Run: l.Notify},
+		Job{Name: JobDispatch, Interval: TickDispatch, Run: l.Dispatch},
+		Job{Name: JobCleanup, Interval: TickCleanup, Jitter: time.Minute, Run: l.Cleanup},
+		Job{Name: JobRefresh, Interval: TickRefresh, Jitter: time.Minute, Run: l.Refresh},
+	)
+
+	return l
 }
 
-func New(db db.DB, sendico *sendico.Client, bot *bot.Bot) *Looper {
-	return &Looper{db, sendico, bot}
+// Start runs the looper's jobs in the background until ctx is done.
+func (l *Looper) Start(ctx context.Context) {
+	l.scheduler.Start(ctx)
 }
 
-func (l *Looper) Notify(ctx context.Context) {
-	ticker := time.NewTicker(TickNotify)
-	defer ticker.Stop()
+// Wait blocks until the looper's jobs are drained, or until ctx is done.
+func (l *Looper) Wait(ctx context.Context) error {
+	return l.scheduler.Wait(ctx)
+}
+
+// Trigger runs the named job as soon as possible.
+func (l *Looper) Trigger(name string) bool {
+	return l.scheduler.Trigger(name)
+}
 
+// State returns the last known state of the named job.
+func (l *Looper) State(name string) (JobState, bool) {
+	return l.scheduler.State(name)
+}
+
+// Notify polls the subscriptions that are due, notifying users of new items.
+func (l *Looper) Notify(ctx context.Context) error {
 	log := slog.With("component", "looper.notify")
-	log.Info("starting loop", "tick", TickNotify)
 
-	for {
+	// the following search/check/notify flow will probably not scale well. should be fine for low volume though
+	termSubs, err := l.db.FindSubscriptionsToNotify(250)
+	if err != nil {
+		return fmt.Errorf("failed to find terms to update: %w", err)
+	}
+
+	load, err := l.db.GetPollLoad()
+	if err != nil {
+		log.Error("failed to get poll load", "err", err)
+	}
+
+	// dispatch once the tick is done, so each user gets a single batch for it
+	found := false
+	l.notifying.Store(true)
+	defer func() {
+		l.notifying.Store(false)
+		if found {
+			l.Trigger(JobDispatch)
+		}
+	}()
+
+	for i, termSub := range termSubs {
+		// subscriptions are ordered by how overdue they are, anything past what is left of the budget waits
+		if l.bucket != nil && !l.bucket.Take(len(termSub.Subscription.Shops()), time.Now(), i == 0) {
+			log.Warn("request budget spent for tick, deferring", "deferred", len(termSubs)-i)
+			break
+		}
+
+		// let's be nice to sendico
 		select {
 		case <-ctx.Done():
-			log.Info("context done, stopping")
-			return
-		case <-ticker.C:
-			// the following search/check/notify flow will probably not scale well. should be fine for low volume though
-			termSubs, err := l.db.FindSubscriptionsToNotify(WindowNotify, 250)
-			if err != nil {
-				log.Error("failed to find terms to update", "err", err)
-				continue
-			}
+			log.Info("context done, stopping early", "remaining", len(termSubs)-i)
+			return nil
+		case <-time.After(2 * time.Second):
+		}
 
-			for _, termSub := range termSubs {
-				// let's be nice to sendico
-				time.Sleep(2 * time.Second)
-
-				results, err := l.sendico.BulkSearch(ctx, termSub.Subscription.Shops(), sendico.SearchOptions{
-					TermJP:   termSub.Term.JP,
-					MinPrice: termSub.Subscription.MinPrice,
-					MaxPrice: termSub.Subscription.MaxPrice,
-				})
-				if err != nil {
-					log.Error("failed to bulk search", "err", err, "term_id", termSub.Term.ID)
-					continue
-				}
-
-				itemMap := make(map[string]sendico.Item)
-				items := make([]db.Item, 0, len(results))
-				for _, item := range results {
-					items = append(items, db.Item{
-						Shop:           item.Shop,
-						Code:           item.Code,
-						SubscriptionID: termSub.Subscription.ID,
-					})
-
-					itemMap[fmt.Sprintf("%s:%s", item.Shop.Identifier(), item.Code)] = item
-				}
-
-				newItems, err := l.db.FilterBySeenItems(items)
-				if err != nil {
-					log.Error("failed to filter by seen items", "err", err)
-					continue
-				}
-
-				if len(newItems) == 0 {
-					log.Info("no new items found", "term_id", termSub.Term.ID)
-					continue
-				}
-
-				log.Info("new items found", "term_id", termSub.Term.ID, "count", len(newItems))
-				if err := l.db.TrackItems(newItems...); err != nil {
-					log.Error("failed to track items", "err", err)
-					continue
-				}
-
-				itemsToNotify := make([]sendico.Item, 0, len(newItems))
-				for _, item := range newItems {
-					key := fmt.Sprintf("%s:%s", item.Shop.Identifier(), item.Code)
-					if item, found := itemMap[key]; found {
-						itemsToNotify = append(itemsToNotify, item)
-					}
-				}
-
-				if err := l.bot.NotifyNewItems(termSub.Term.EN, termSub.Subscription.UserID, itemsToNotify); err != nil {
-					log.Error("failed to notify new items", "err", err, "term_id", termSub.Term.ID, "user_id", termSub.Subscription.UserID)
-					continue
-				}
-			}
+		sub := termSub.Subscription
+		now := time.Now().UTC()
 
-			subIDs := make([]string, 0, len(termSubs))
-			for _, termSub := range termSubs {
-				subIDs = append(subIDs, termSub.Subscription.ID)
-			}
+		catchUp := MissedDowntime(sub.NextPollAt, l.started)
+		if catchUp {
+			log.Info("subscription was due while offline, catching up", "sub_id", sub.ID, "due_at", *sub.NextPollAt)
+		}
 
-			if err := l.db.SetNotified(subIDs...); err != nil {
-				log.Error("failed to set notified", "err", err)
-				continue
+		// finish this subscription even if we are shutting down, so items aren't tracked without a notification
+		var schedule Schedule
+		newItems, err := l.poll(context.WithoutCancel(ctx), termSub.Term, &sub, catchUp)
+		if err != nil {
+			// a failed search says nothing about how busy the term is, try again on the same schedule
+			log.Error("failed to poll", "err", err, "term_id", termSub.Term.ID, "user_id", sub.UserID)
+			schedule = Schedule{Interval: sub.PollInterval, Reason: sub.PollReason, Rate: sub.NewItemRate}
+
+			if errors.Is(err, sendico.ErrRequest) {
+				// most likely the HMAC secret was rotated
+				l.Trigger(JobRefresh)
 			}
+		} else {
+			schedule = NextSchedule(&sub, newItems, now, load, l.budget)
+		}
+
+		nextPollAt := now.Add(schedule.Interval)
+
+		sub.LastNotifiedAt = now
+		sub.PollInterval = schedule.Interval
+		sub.PollReason = schedule.Reason
+		sub.NewItemRate = schedule.Rate
+		sub.NextPollAt = &nextPollAt
+
+		if err := l.db.UpdateSchedule(&sub); err != nil {
+			log.Error("failed to update schedule", "err", err, "sub_id", sub.ID)
+			continue
 		}
+
+		log.Debug("rescheduled", "sub_id", sub.ID, "interval", schedule.Interval, "reason", schedule.Reason)
+
+		found = found || newItems > 0
 	}
+
+	return nil
+}
+
+// MissedDowntime returns whether a subscription was due more than CatchUpThreshold before the bot started, so the bot
+// was offline when it should have been polled. Subscriptions that fall behind while the bot is running, e.g. because
+// the request budget is spent, aren't caught up, they weren't missed because of downtime.
+func MissedDowntime(nextPollAt *time.Time, started time.Time) bool {
+	return nextPollAt != nil && started.Sub(*nextPollAt) > CatchUpThreshold
 }
 
-func (l *Looper) Cleanup(ctx context.Context) {
-	ticker := time.NewTicker(TickCleanup)
-	defer ticker.Stop()
+// poll searches for a subscription's term, tracks and queues any new items for delivery. Items are new if they were
+// listed since the subscription's high-water mark and haven't been seen before, the mark is moved forward once they are
+// queued. If catchUp is set, the items are summarized as having piled up while the bot was away. It returns the number
+// of new items found.
+func (l *Looper) poll(ctx context.Context, term db.Term, sub *db.Subscription, catchUp bool) (int, error) {
+	results, err := l.sendico.BulkSearch(ctx, sub.Shops(), sub.SearchOptions(term))
+	if err != nil {
+		return 0, fmt.Errorf("failed to bulk search: %w", err)
+	}
 
-	log := slog.With("component", "looper.cleanup")
-	log.Info("starting loop", "tick", TickCleanup)
+	firstSeen, err := l.db.GetFirstSeen(results...)
+	if err != nil {
+		// without first seen times every listing time is trusted, the seen items still catch repeats
+		slog.Error("failed to get first seen times", "err", err, "component", "looper.notify", "sub_id", sub.ID)
+	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			log.Info("context done, stopping")
-			return
-		case <-ticker.C:
-			if err := l.db.CleanupItems(WindowCleanup); err != nil {
-				log.Error("failed to cleanup items", "err", err)
-				continue
-			}
+	// deals are scored against the prices known before this search, or each new item would count towards its own usual
+	// price. The snapshots are saved right after, dedup keeps the image hashes in them.
+	var prices map[sendico.Shop][]int
+	if sub.DealThreshold != nil {
+		if prices, err = l.db.GetTermPrices(term.ID, time.Now().Add(-DealWindow)); err != nil {
+			return 0, fmt.Errorf("failed to get term prices: %w", err)
 		}
 	}
-}
 
-func (l *Looper) Refresh(ctx context.Context) {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-
-	log := slog.With("component", "looper.refresh")
-	log.Info("starting loop", "tick", TickRefresh)
-
-	select {
-	case <-ctx.Done():
-		log.Info("context done, stopping")
-		return
-	case <-ticker.C:
-		if err := l.sendico.FindHMAC(ctx); err != nil {
-			log.Error("failed to refresh HMAC secret key", "err", err)
-			return
+	if err := l.db.SaveSnapshots(term.ID, results...); err != nil {
+		// snapshots are only kept for history, they don't change what is new
+		slog.Error("failed to save snapshots", "err", err, "component", "looper.notify", "sub_id", sub.ID)
+	}
+
+	results, mark := ListedSince(results, sub.HighWaterMark, firstSeen)
+
+	itemMap := make(map[string]sendico.Item)
+	items := make([]db.Item, 0, len(results))
+	for _, item := range results {
+		items = append(items, db.Item{
+			Shop:           item.Shop,
+			Code:           item.Code,
+			SubscriptionID: sub.ID,
+		})
+
+		itemMap[fmt.Sprintf("%s:%s", item.Shop.Identifier(), item.Code)] = item
+	}
+
+	newItems, err := l.db.FilterBySeenItems(items)
+	if err != nil {
+		return 0, fmt.Errorf("failed to filter by seen items: %w", err)
+	}
+
+	log := slog.With("component", "looper.notify", "term_id", term.ID)
+	if len(newItems) == 0 {
+		log.Info("no new items found")
+		sub.HighWaterMark = mark
+		return 0, nil
+	}
+
+	log.Info("new items found", "count", len(newItems))
+
+	itemsToNotify := make([]sendico.Item, 0, len(newItems))
+	for _, item := range newItems {
+		key := fmt.Sprintf("%s:%s", item.Shop.Identifier(), item.Code)
+		if item, found := itemMap[key]; found {
+			itemsToNotify = append(itemsToNotify, item)
 		}
 	}
+
+	sellers, err := l.db.GetSellers(sub.UserID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get sellers: %w", err)
+	}
+
+	// items from followed sellers are sent even if the votes or the deal threshold would hold them back
+	itemsToNotify, followed := FilterSellers(itemsToNotify, sellers)
+	if sub.SellerID != nil {
+		// a seller watch only finds the followed seller's items, even on shops whose items don't say who sells them
+		itemsToNotify = FromSeller(itemsToNotify, *sub.SellerID)
+		followAll(itemsToNotify, followed)
+	}
+
+	var doubts map[string]db.ItemNote
+	feedback, err := l.db.GetFeedback(sub.ID)
+	if err != nil {
+		// without the votes nothing is held back
+		log.Error("failed to get feedback", "err", err)
+	} else if model, ok := relevance.Train(feedback); ok {
+		var judged []sendico.Item
+		judged, doubts = JudgeRelevance(itemsToNotify, model)
+		itemsToNotify = withFollowed(itemsToNotify, judged, followed)
+		log.Info("judged relevance", "count", len(itemsToNotify), "votes", len(feedback))
+	}
+
+	itemsToNotify, notes := l.Dedup(ctx, sub, itemsToNotify)
+	mergeNotes(notes, followed)
+	mergeNotes(notes, doubts)
+
+	if sub.DealThreshold != nil {
+		deals, dealNotes := ScoreDeals(itemsToNotify, prices, *sub.DealThreshold)
+		itemsToNotify = withFollowed(itemsToNotify, deals, followed)
+		log.Info("scored deals", "count", len(itemsToNotify), "threshold", *sub.DealThreshold)
+		mergeNotes(notes, dealNotes)
+	}
+
+	user, err := l.db.GetUser(sub.UserID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	reason := db.OutboxReasonNew
+	if catchUp {
+		reason = db.OutboxReasonCatchUp
+	}
+
+	deliverAt, digest := DeliverAt(user, time.Now())
+	// if none of the new items made it through the sellers, votes, dedup and deal threshold, they are only tracked
+	entries := make([]*db.OutboxEntry, 0, len(sub.NotifierNames()))
+	for _, notifier := range sub.NotifierNames() {
+		if len(itemsToNotify) == 0 {
+			break
+		}
+
+		entries = append(entries, &db.OutboxEntry{
+			SubscriptionID: sub.ID,
+			Items:          itemsToNotify,
+			NextAttemptAt:  deliverAt,
+			Digest:         digest,
+			Reason:         reason,
+			Notifier:       notifier,
+			Notes:          notes,
+		})
+	}
+
+	if err := l.db.EnqueueItems(entries, newItems...); err != nil {
+		return 0, fmt.Errorf("failed to enqueue items: %w", err)
+	}
+
+	if err := l.db.SaveMatches(sub.ID, itemsToNotify...); err != nil {
+		// they are already queued, the subscription's feed just won't have them
+		log.Error("failed to save matches", "err", err)
+	}
+
+	sub.HighWaterMark = mark
+	return len(newItems), nil
+}
+
+// Cleanup moves tracked items older than WindowCleanup into the long-lived seen history, removes delivered outbox
+// entries older than WindowCleanup and undelivered ones older than WindowUndelivered, removes matches older than
+// WindowMatches, and forgets the snapshots of items that haven't been seen within WindowSnapshots.
+func (l *Looper) Cleanup(ctx context.Context) error {
+	if err := l.db.CleanupItems(WindowCleanup); err != nil {
+		return fmt.Errorf("failed to cleanup items: %w", err)
+	}
+	if err := l.db.CleanupOutbox(WindowCleanup, WindowUndelivered); err != nil {
+		return fmt.Errorf("failed to cleanup outbox: %w", err)
+	}
+	if err := l.db.CleanupMatches(WindowMatches); err != nil {
+		return fmt.Errorf("failed to cleanup matches: %w", err)
+	}
+	if err := l.db.CleanupSnapshots(WindowSnapshots); err != nil {
+		return fmt.Errorf("failed to cleanup snapshots: %w", err)
+	}
+	return nil
+}
+
+// Refresh refreshes the Sendico HMAC secret key.
+func (l *Looper) Refresh(ctx context.Context) error {
+	if err := l.sendico.FindHMAC(ctx); err != nil {
+		return fmt.Errorf("failed to refresh HMAC secret key: %w", err)
+	}
+	return nil
 }
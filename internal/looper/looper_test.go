@@ -0,0 +1,22 @@
+package looper_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robherley/sendibot/internal/looper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMissedDowntime(t *testing.T) {
+	started := time.Date(2024, 11, 14, 12, 0, 0, 0, time.UTC)
+
+	// due long before the bot started, it was offline
+	assert.True(t, looper.MissedDowntime(ptr(started.Add(-3*time.Hour)), started))
+	// due just before the bot started
+	assert.False(t, looper.MissedDowntime(ptr(started.Add(-10*time.Minute)), started))
+	// fell behind while the bot was running, e.g. deferred by the request budget
+	assert.False(t, looper.MissedDowntime(ptr(started.Add(2*time.Hour)), started))
+	// never polled
+	assert.False(t, looper.MissedDowntime(nil, started))
+}
@@ -0,0 +1,49 @@
+package looper
+
+import (
+	"math"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/relevance"
+	"github.com/robherley/sendibot/pkg/sendico"
+)
+
+// JudgeRelevance drops the items a subscription's votes say are almost surely irrelevant, and notes how likely the
+// rest are to be if they are past relevance.MarkThreshold. It returns the kept items and their notes.
+func JudgeRelevance(items []sendico.Item, model *relevance.Model) ([]sendico.Item, map[string]db.ItemNote) {
+	kept := make([]sendico.Item, 0, len(items))
+	notes := make(map[string]db.ItemNote)
+	for _, item := range items {
+		irrelevance := model.Irrelevance(item.Name)
+		if irrelevance >= relevance.SuppressThreshold {
+			continue
+		}
+
+		kept = append(kept, item)
+		if irrelevance >= relevance.MarkThreshold {
+			notes[db.NoteKey(item)] = db.ItemNote{Irrelevance: int(math.Round(irrelevance * 100))}
+		}
+	}
+
+	return kept, notes
+}
+
+// mergeNotes adds what more says about items to notes.
+func mergeNotes(notes, more map[string]db.ItemNote) {
+	for key, note := range more {
+		merged := notes[key]
+		if note.Deal != nil {
+			merged.Deal = note.Deal
+		}
+		if len(note.Variants) > 0 {
+			merged.Variants = note.Variants
+		}
+		if note.Followed {
+			merged.Followed = true
+		}
+		if note.Irrelevance > 0 {
+			merged.Irrelevance = note.Irrelevance
+		}
+		notes[key] = merged
+	}
+}
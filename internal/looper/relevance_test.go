@@ -0,0 +1,35 @@
+package looper_test
+
+import (
+	"testing"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/looper"
+	"github.com/robherley/sendibot/internal/relevance"
+	"github.com/robherley/sendibot/pkg/sendico"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJudgeRelevance(t *testing.T) {
+	var feedback []db.Feedback
+	for _, name := range []string{"ピカチュウ プロモ", "ピカチュウ ex", "ピカチュウ ar", "ピカチュウ sar", "ピカチュウ psa10"} {
+		feedback = append(feedback, db.Feedback{Name: name, Vote: db.VoteUp})
+	}
+	for _, name := range []string{"ぬいぐるみ 特大", "ぬいぐるみ タグ付き", "ぬいぐるみ ポケセン", "ぬいぐるみ まとめ", "ぬいぐるみ 等身大"} {
+		feedback = append(feedback, db.Feedback{Name: name, Vote: db.VoteDown})
+	}
+
+	model, ok := relevance.Train(feedback)
+	require.True(t, ok)
+
+	card := sendico.Item{Shop: sendico.Mercari, Code: "m1", Name: "ピカチュウ プロモ 未開封"}
+	plush := sendico.Item{Shop: sendico.Mercari, Code: "m2", Name: "ぬいぐるみ ポケセン 特大 タグ付き まとめ"}
+	doubtful := sendico.Item{Shop: sendico.Mercari, Code: "m3", Name: "ピカチュウ ex ぬいぐるみ タグ付き"}
+
+	items, notes := looper.JudgeRelevance([]sendico.Item{card, plush, doubtful}, model)
+	assert.Equal(t, []sendico.Item{card, doubtful}, items)
+	assert.Contains(t, notes, db.NoteKey(doubtful))
+	assert.Equal(t, 91, notes[db.NoteKey(doubtful)].Irrelevance)
+	assert.NotContains(t, notes, db.NoteKey(card))
+}
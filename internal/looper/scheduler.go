@@ -0,0 +1,203 @@
+package looper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// DefaultBackoff is the first retry delay of a job that failed, it doubles on every consecutive failure.
+const DefaultBackoff = 30 * time.Second
+
+// Job is a named function that is run periodically by a Scheduler.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	// Jitter is the maximum random delay added to each run, to keep jobs from lining up.
+	Jitter time.Duration
+	// Backoff is the first retry delay after a failure, defaults to DefaultBackoff. Retries are capped at Interval.
+	Backoff time.Duration
+	Run     func(context.Context) error
+}
+
+// JobState is the last known state of a job.
+type JobState struct {
+	Name      string
+	Running   bool
+	LastRun   time.Time
+	LastError error
+	Failures  int
+	NextRun   time.Time
+}
+
+type scheduledJob struct {
+	Job
+	trigger chan struct{}
+	state   JobState
+}
+
+// Scheduler runs jobs periodically until its context is done. Failed jobs are retried with exponential backoff, and
+// jobs can be triggered to run right away.
+type Scheduler struct {
+	mu   sync.Mutex
+	jobs map[string]*scheduledJob
+	wg   sync.WaitGroup
+}
+
+func NewScheduler(jobs ...Job) *Scheduler {
+	s := &Scheduler{
+		jobs: make(map[string]*scheduledJob, len(jobs)),
+	}
+
+	for _, job := range jobs {
+		if job.Backoff == 0 {
+			job.Backoff = DefaultBackoff
+		}
+
+		s.jobs[job.Name] = &scheduledJob{
+			Job:     job,
+			trigger: make(chan struct{}, 1),
+			state:   JobState{Name: job.Name},
+		}
+	}
+
+	return s
+}
+
+// Start runs every job in the background until ctx is done. Jobs get ctx too, so they should stop at a safe point
+// once it is done. Use Wait to drain them.
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, job)
+	}
+}
+
+// Wait blocks until every job has returned, or until ctx is done.
+func (s *Scheduler) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("jobs did not drain: %w", ctx.Err())
+	}
+}
+
+// Trigger runs the named job as soon as possible. It returns false if there is no such job.
+func (s *Scheduler) Trigger(name string) bool {
+	job, ok := s.jobs[name]
+	if !ok {
+		return false
+	}
+
+	select {
+	case job.trigger <- struct{}{}:
+	default:
+		// already triggered
+	}
+
+	return true
+}
+
+// State returns the last known state of the named job.
+func (s *Scheduler) State(name string) (JobState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[name]
+	if !ok {
+		return JobState{}, false
+	}
+
+	return job.state, true
+}
+
+func (s *Scheduler) loop(ctx context.Context, job *scheduledJob) {
+	defer s.wg.Done()
+
+	log := slog.With("component", "looper."+job.Name)
+	log.Info("starting loop", "interval", job.Interval, "jitter", job.Jitter)
+
+	delay := job.Interval
+	for {
+		delay += s.jitter(job)
+		s.setState(job, func(state *JobState) {
+			state.NextRun = time.Now().Add(delay)
+		})
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			log.Info("context done, stopping")
+			return
+		case <-job.trigger:
+			timer.Stop()
+			log.Info("triggered")
+		case <-timer.C:
+		}
+
+		err := s.run(ctx, job)
+
+		var failures int
+		s.setState(job, func(state *JobState) {
+			state.LastRun = time.Now()
+			state.LastError = err
+			if err != nil {
+				state.Failures++
+			} else {
+				state.Failures = 0
+			}
+			failures = state.Failures
+		})
+
+		delay = job.Interval
+		if err != nil {
+			// cap the shift, the interval is the ceiling anyway
+			delay = min(job.Backoff<<min(failures-1, 16), job.Interval)
+			log.Error("failed", "err", err, "failures", failures, "retry", delay)
+		}
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, job *scheduledJob) (err error) {
+	s.setState(job, func(state *JobState) {
+		state.Running = true
+	})
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+			slog.Error("panic", "job", job.Name, "err", r, "stack", string(debug.Stack()))
+		}
+
+		s.setState(job, func(state *JobState) {
+			state.Running = false
+		})
+	}()
+
+	return job.Run(ctx)
+}
+
+func (s *Scheduler) jitter(job *scheduledJob) time.Duration {
+	if job.Jitter <= 0 {
+		return 0
+	}
+	return rand.N(job.Jitter)
+}
+
+func (s *Scheduler) setState(job *scheduledJob, fn func(*JobState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&job.state)
+}
@@ -0,0 +1,94 @@
+package looper_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/robherley/sendibot/internal/looper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var runs, fails atomic.Int32
+	s := looper.NewScheduler(
+		looper.Job{
+			Name:     "ok",
+			Interval: time.Hour,
+			Run: func(ctx context.Context) error {
+				runs.Add(1)
+				return nil
+			},
+		},
+		looper.Job{
+			Name:     "fail",
+			Interval: time.Hour,
+			Backoff:  10 * time.Millisecond,
+			Run: func(ctx context.Context) error {
+				fails.Add(1)
+				return errors.New("oops")
+			},
+		},
+	)
+	s.Start(ctx)
+
+	assert.True(t, s.Trigger("ok"))
+	assert.False(t, s.Trigger("missing"))
+	assert.Eventually(t, func() bool {
+		state, _ := s.State("ok")
+		return runs.Load() == 1 && !state.LastRun.IsZero()
+	}, time.Second, 5*time.Millisecond)
+
+	state, ok := s.State("ok")
+	assert.True(t, ok)
+	assert.NoError(t, state.LastError)
+	assert.Equal(t, 0, state.Failures)
+
+	// the first run waits for the interval, then failures back off from 10ms
+	s.Trigger("fail")
+	assert.Eventually(t, func() bool {
+		return fails.Load() >= 3
+	}, time.Second, 5*time.Millisecond)
+
+	state, _ = s.State("fail")
+	assert.Error(t, state.LastError)
+	assert.GreaterOrEqual(t, state.Failures, 2)
+
+	cancel()
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), time.Second)
+	defer drainCancel()
+	assert.NoError(t, s.Wait(drainCtx))
+}
+
+func TestSchedulerDrain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	started := make(chan struct{})
+	s := looper.NewScheduler(looper.Job{
+		Name:     "slow",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		},
+	})
+	s.Start(ctx)
+	s.Trigger("slow")
+	<-started
+	cancel()
+
+	short, shortCancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer shortCancel()
+	assert.Error(t, s.Wait(short))
+
+	long, longCancel := context.WithTimeout(context.Background(), time.Second)
+	defer longCancel()
+	assert.NoError(t, s.Wait(long))
+}
@@ -0,0 +1,78 @@
+package looper
+
+import (
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/pkg/sendico"
+)
+
+// FilterSellers drops the items from sellers the user blocked, and notes the ones from sellers they follow. It returns
+// the kept items and their notes.
+func FilterSellers(items []sendico.Item, sellers []db.Seller) ([]sendico.Item, map[string]db.ItemNote) {
+	statuses := make(map[string]db.SellerStatus, len(sellers))
+	for _, seller := range sellers {
+		statuses[seller.Key()] = seller.Status
+	}
+
+	kept := make([]sendico.Item, 0, len(items))
+	notes := make(map[string]db.ItemNote)
+	for _, item := range items {
+		sellerID, ok := item.SellerID()
+		if !ok {
+			kept = append(kept, item)
+			continue
+		}
+
+		switch statuses[db.Seller{Shop: item.Shop, ID: sellerID}.Key()] {
+		case db.SellerBlocked:
+			continue
+		case db.SellerFollowed:
+			notes[db.NoteKey(item)] = db.ItemNote{Followed: true}
+		}
+		kept = append(kept, item)
+	}
+
+	return kept, notes
+}
+
+// FromSeller keeps the items listed by the seller a subscription watches. The search only asks Sendico for the seller's
+// items, items it returns from other sellers are dropped. Items of shops that don't say who sells them can't be checked
+// and are kept.
+func FromSeller(items []sendico.Item, sellerID string) []sendico.Item {
+	kept := make([]sendico.Item, 0, len(items))
+	for _, item := range items {
+		id, ok := item.SellerID()
+		if (ok && id != sellerID) || (!ok && item.Shop.HasSellers()) {
+			continue
+		}
+		kept = append(kept, item)
+	}
+
+	return kept
+}
+
+// withFollowed adds the items from followed sellers that a filter dropped back to what it kept, in their order in all.
+func withFollowed(all, kept []sendico.Item, notes map[string]db.ItemNote) []sendico.Item {
+	keys := make(map[string]struct{}, len(kept))
+	for _, item := range kept {
+		keys[db.NoteKey(item)] = struct{}{}
+	}
+
+	merged := make([]sendico.Item, 0, len(all))
+	for _, item := range all {
+		key := db.NoteKey(item)
+		if _, ok := keys[key]; ok || notes[key].Followed {
+			merged = append(merged, item)
+		}
+	}
+
+	return merged
+}
+
+// followAll notes every item as from a followed seller.
+func followAll(items []sendico.Item, notes map[string]db.ItemNote) {
+	for _, item := range items {
+		note := notes[db.NoteKey(item)]
+		note.Followed = true
+		notes[db.NoteKey(item)] = note
+	}
+}
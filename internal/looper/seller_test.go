@@ -0,0 +1,42 @@
+package looper_test
+
+import (
+	"testing"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/looper"
+	"github.com/robherley/sendibot/pkg/sendico"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterSellers(t *testing.T) {
+	followed := sendico.Item{Shop: sendico.Rakuten, Code: "goodstore:1"}
+	blocked := sendico.Item{Shop: sendico.Yahoo, Code: "spamstore_2", URL: "https://store.shopping.yahoo.co.jp/spamstore/2.html"}
+	other := sendico.Item{Shop: sendico.Rakuten, Code: "otherstore:3"}
+	// same seller ID, different shop
+	lookalike := sendico.Item{Shop: sendico.Yahoo, Code: "goodstore_4"}
+	mercari := sendico.Item{Shop: sendico.Mercari, Code: "m5"}
+
+	sellers := []db.Seller{
+		{Shop: sendico.Rakuten, ID: "goodstore", Status: db.SellerFollowed},
+		{Shop: sendico.Yahoo, ID: "spamstore", Status: db.SellerBlocked},
+	}
+
+	items, notes := looper.FilterSellers([]sendico.Item{followed, blocked, other, lookalike, mercari}, sellers)
+	assert.Equal(t, []sendico.Item{followed, other, lookalike, mercari}, items)
+	assert.Equal(t, map[string]db.ItemNote{
+		db.NoteKey(followed): {Followed: true},
+	}, notes)
+}
+
+func TestFromSeller(t *testing.T) {
+	watched := sendico.Item{Shop: sendico.Rakuten, Code: "goodstore:1"}
+	other := sendico.Item{Shop: sendico.Rakuten, Code: "otherstore:2"}
+	// no store in the code, so it can't be told apart from other sellers
+	unknown := sendico.Item{Shop: sendico.Rakuten, Code: "3"}
+	// Mercari items don't say who sells them, the search is trusted
+	mercari := sendico.Item{Shop: sendico.Mercari, Code: "m4"}
+
+	items := looper.FromSeller([]sendico.Item{watched, other, unknown, mercari}, "goodstore")
+	assert.Equal(t, []sendico.Item{watched, mercari}, items)
+}
@@ -0,0 +1,48 @@
+package looper
+
+import (
+	"time"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/pkg/sendico"
+)
+
+// ListedAt returns when the item was listed, going by when its photos were uploaded. That is only a heuristic: editing
+// the photos of an older listing moves the upload time forward, so an upload after the item was first seen (firstSeen,
+// keyed by db.NoteKey) is an edit and not a listing time.
+func ListedAt(item sendico.Item, firstSeen map[string]time.Time) (time.Time, bool) {
+	uploadedAt, ok := item.ImageUploadedAt()
+	if !ok {
+		return time.Time{}, false
+	}
+
+	if seenAt, found := firstSeen[db.NoteKey(item)]; found && uploadedAt.After(seenAt) {
+		return time.Time{}, false
+	}
+
+	return uploadedAt, true
+}
+
+// ListedSince filters out items that were listed before the high-water mark, so listings that come back after a
+// cleanup or a change to the subscription aren't mistaken for new ones. Items without a trustworthy listing time (see
+// ListedAt) are kept, it is up to the seen items to decide if they are new. A nil mark keeps every item.
+//
+// It also returns the next high-water mark: the listing time of the newest item, or mark if that is later.
+func ListedSince(items []sendico.Item, mark *time.Time, firstSeen map[string]time.Time) ([]sendico.Item, *time.Time) {
+	next := mark
+	kept := make([]sendico.Item, 0, len(items))
+	for _, item := range items {
+		listedAt, ok := ListedAt(item, firstSeen)
+		if ok && (next == nil || listedAt.After(*next)) {
+			next = &listedAt
+		}
+
+		// items listed in the same second as the mark may not have been seen yet
+		if ok && mark != nil && listedAt.Before(*mark) {
+			continue
+		}
+		kept = append(kept, item)
+	}
+
+	return kept, next
+}
@@ -0,0 +1,90 @@
+package looper_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/robherley/sendibot/internal/looper"
+	"github.com/robherley/sendibot/pkg/sendico"
+	"github.com/stretchr/testify/assert"
+)
+
+func listed(code string, at time.Time) sendico.Item {
+	return sendico.Item{
+		Shop:  sendico.Mercari,
+		Code:  code,
+		Image: fmt.Sprintf("https://static.mercdn.net/thumb/photos/%s_1.jpg?%d", code, at.Unix()),
+	}
+}
+
+func TestListedSince(t *testing.T) {
+	mark := time.Date(2024, 11, 14, 12, 0, 0, 0, time.UTC)
+
+	older := listed("m1", mark.Add(-time.Hour))
+	same := listed("m2", mark)
+	newer := listed("m3", mark.Add(time.Minute))
+	untimed := sendico.Item{Shop: sendico.YahooAuctions, Code: "a1"}
+	// an old listing whose photos were edited after it was first seen
+	edited := listed("m4", mark.Add(time.Hour))
+	firstSeen := map[string]time.Time{"mercari:m4": mark.Add(-24 * time.Hour)}
+
+	tc := []struct {
+		name      string
+		items     []sendico.Item
+		mark      *time.Time
+		firstSeen map[string]time.Time
+		want      []sendico.Item
+		wantMark  *time.Time
+	}{
+		{
+			name:     "no mark keeps everything",
+			items:    []sendico.Item{older, untimed},
+			want:     []sendico.Item{older, untimed},
+			wantMark: ptr(mark.Add(-time.Hour)),
+		},
+		{
+			name:     "drops items listed before the mark",
+			items:    []sendico.Item{older, same, newer, untimed},
+			mark:     &mark,
+			want:     []sendico.Item{same, newer, untimed},
+			wantMark: ptr(mark.Add(time.Minute)),
+		},
+		{
+			name:     "mark never goes back",
+			items:    []sendico.Item{older},
+			mark:     &mark,
+			want:     []sendico.Item{},
+			wantMark: &mark,
+		},
+		{
+			name:      "edited listings are kept without moving the mark",
+			items:     []sendico.Item{older, edited},
+			mark:      &mark,
+			firstSeen: firstSeen,
+			want:      []sendico.Item{edited},
+			wantMark:  &mark,
+		},
+		{
+			name:      "uploads before the first sighting are trusted",
+			items:     []sendico.Item{newer},
+			mark:      &mark,
+			firstSeen: map[string]time.Time{"mercari:m3": mark.Add(time.Hour)},
+			want:      []sendico.Item{newer},
+			wantMark:  ptr(mark.Add(time.Minute)),
+		},
+		{
+			name:  "no listing times",
+			items: []sendico.Item{untimed},
+			want:  []sendico.Item{untimed},
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotMark := looper.ListedSince(tt.items, tt.mark, tt.firstSeen)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantMark, gotMark)
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/robherley/sendibot/internal/db"
+)
+
+// DefaultEmailTimeout is how long a whole SMTP conversation may take.
+const DefaultEmailTimeout = 30 * time.Second
+
+// SMTPConfig is the server emails are sent through.
+type SMTPConfig struct {
+	Host string
+	Port int
+	// Username and Password authenticate with PLAIN auth, which needs TLS unless the server is on localhost. No auth
+	// is used if Username is empty.
+	Username string
+	Password string
+	// From is the address emails are sent from, e.g. "sendibot <sendibot@example.com>".
+	From string
+}
+
+type EmailOption func(*Email)
+
+func WithEmailTimeout(timeout time.Duration) EmailOption {
+	return func(e *Email) {
+		e.timeout = timeout
+	}
+}
+
+// Email sends events to a user's verified email address over SMTP, as HTML with a plain text alternative.
+type Email struct {
+	db      db.DB
+	config  SMTPConfig
+	timeout time.Duration
+}
+
+var _ Notifier = (*Email)(nil)
+
+func NewEmail(db db.DB, config SMTPConfig, opts ...EmailOption) *Email {
+	e := &Email{
+		db:      db,
+		config:  config,
+		timeout: DefaultEmailTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+func (e *Email) Name() string {
+	return db.EmailNotifier
+}
+
+// Notify sends each user's events as one email.
+func (e *Email) Notify(ctx context.Context, events ...Event) error {
+	var users []string
+	byUser := make(map[string][]Event)
+	for _, event := range events {
+		userID := event.Subscription.UserID
+		if _, ok := byUser[userID]; !ok {
+			users = append(users, userID)
+		}
+		byUser[userID] = append(byUser[userID], event)
+	}
+
+	for _, userID := range users {
+		email, err := e.db.GetEmail(userID)
+		if err != nil {
+			return err
+		}
+
+		if !email.IsVerified() {
+			return NewUnreachableError(fmt.Errorf("user %q has no verified email address", userID))
+		}
+
+		msg, err := e.message(email.Address, byUser[userID])
+		if err != nil {
+			return err
+		}
+
+		if err := e.send(ctx, email.Address, msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SendConfirmCode emails the code that verifies an address.
+func (e *Email) SendConfirmCode(ctx context.Context, address, code string) error {
+	subject := "Your sendibot confirm code is " + code
+	text := fmt.Sprintf("Use /email confirm with the code %s to start getting sendibot notifications at this address.\n\nIf you didn't ask for this, you can ignore this email.\n", code)
+	html := fmt.Sprintf("<p>Use <code>/email confirm</code> with the code <strong>%s</strong> to start getting sendibot notifications at this address.</p><p>If you didn't ask for this, you can ignore this email.</p>", htmltemplate.HTMLEscapeString(code))
+
+	msg, err := e.build(address, subject, text, html)
+	if err != nil {
+		return err
+	}
+
+	return e.send(ctx, address, msg)
+}
+
+type emailTerm struct {
+	EN    string
+	JP    string
+	Items []emailItem
+}
+
+type emailItem struct {
+	Name       string
+	Shop       string
+	Image      string
+	PriceYen   int
+	PriceUSD   int
+	URL        string
+	SendicoURL string
+}
+
+var emailText = texttemplate.Must(texttemplate.New("text").Parse(`{{ range . }}{{ .EN }} ({{ .JP }})
+{{ range .Items }}
+- {{ .Name }}
+  ¥{{ .PriceYen }} (${{ .PriceUSD }}) on {{ .Shop }}
+  {{ .SendicoURL }}
+  {{ .URL }}
+{{ end }}
+{{ end }}`))
+
+var emailHTML = htmltemplate.Must(htmltemplate.New("html").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif;">
+{{ range . }}
+<h2>{{ .EN }} <small>({{ .JP }})</small></h2>
+<table cellpadding="8">
+{{ range .Items }}
+<tr>
+<td>{{ if .Image }}<a href="{{ .SendicoURL }}"><img src="{{ .Image }}" alt="" width="120"></a>{{ end }}</td>
+<td>
+<a href="{{ .SendicoURL }}"><strong>{{ .Name }}</strong></a><br>
+¥{{ .PriceYen }} (${{ .PriceUSD }}) on {{ .Shop }}<br>
+<a href="{{ .SendicoURL }}">Sendico</a> · <a href="{{ .URL }}">{{ .Shop }}</a>
+</td>
+</tr>
+{{ end }}
+</table>
+{{ end }}
+</body>
+</html>
+`))
+
+func (e *Email) message(to string, events []Event) ([]byte, error) {
+	total := 0
+	terms := make([]emailTerm, 0, len(events))
+	for _, event := range events {
+		term := emailTerm{EN: event.Term.EN, JP: event.Term.JP}
+		for _, item := range event.Items {
+			term.Items = append(term.Items, emailItem{
+				Name:       item.Name,
+				Shop:       item.Shop.Name(),
+				Image:      item.Image,
+				PriceYen:   item.PriceYen,
+				PriceUSD:   item.PriceUSD,
+				URL:        item.URL,
+				SendicoURL: item.SendicoLink(),
+			})
+		}
+		total += len(term.Items)
+		terms = append(terms, term)
+	}
+
+	var subject string
+	switch events[0].Reason {
+	case ReasonDigest:
+		subject = fmt.Sprintf("📬 Your sendibot digest: %d new item(s)", total)
+	case ReasonCatchUp:
+		subject = fmt.Sprintf("👋 %d new item(s) while sendibot was away", total)
+	default:
+		names := make([]string, 0, len(terms))
+		for _, term := range terms {
+			names = append(names, strconv.Quote(term.EN))
+		}
+		subject = fmt.Sprintf("🔔 %d new item(s) for %s", total, strings.Join(names, ", "))
+	}
+
+	var text, html bytes.Buffer
+	if err := emailText.Execute(&text, terms); err != nil {
+		return nil, err
+	}
+	if err := emailHTML.Execute(&html, terms); err != nil {
+		return nil, err
+	}
+
+	return e.build(to, subject, text.String(), html.String())
+}
+
+// build renders a multipart/alternative message with a plain text and an HTML part.
+func (e *Email) build(to, subject, text, html string) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	headers := [][2]string{
+		{"From", e.config.From},
+		{"To", to},
+		{"Subject", mime.QEncoding.Encode("utf-8", subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", "<" + hex.EncodeToString(id) + "@sendibot>"},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + parts.Boundary()},
+	}
+	for _, header := range headers {
+		msg.WriteString(header[0] + ": " + header[1] + "\r\n")
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
+
+// send delivers a message the way smtp.SendMail does, but bounded by the context and timeout.
+func (e *Email) send(ctx context.Context, to string, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(e.config.Host, strconv.Itoa(e.config.Port))
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	c, err := smtp.NewClient(conn, e.config.Host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: e.config.Host}); err != nil {
+			return err
+		}
+	}
+
+	if e.config.Username != "" {
+		auth := smtp.PlainAuth("", e.config.Username, e.config.Password, e.config.Host)
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	from := e.config.From
+	if parsed, err := mail.ParseAddress(from); err == nil {
+		from = parsed.Address
+	}
+
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return classifySMTPError(err)
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// classifySMTPError wraps errors about a recipient that will never accept mail with ErrUnreachable.
+func classifySMTPError(err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		switch protoErr.Code {
+		case 550, 551, 553:
+			return NewUnreachableError(err)
+		}
+	}
+	return err
+}
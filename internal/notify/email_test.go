@@ -0,0 +1,225 @@
+package notify_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// smtpServer is a stand-in SMTP server that accepts every message, rejecting recipients in reject.
+type smtpServer struct {
+	listener net.Listener
+	reject   string
+
+	mu       sync.Mutex
+	messages []smtpMessage
+}
+
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	s := &smtpServer{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *smtpServer) config() notify.SMTPConfig {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return notify.SMTPConfig{
+		Host: addr.IP.String(),
+		Port: addr.Port,
+		From: "sendibot <sendibot@example.com>",
+	}
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+
+	var msg smtpMessage
+	reply("220 localhost ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL":
+			msg = smtpMessage{from: arg}
+			reply("250 ok")
+		case "RCPT":
+			if s.reject != "" && strings.Contains(arg, s.reject) {
+				reply("550 no such user")
+				continue
+			}
+			msg.to = append(msg.to, arg)
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			msg.data = data.String()
+
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+// parts reads the plain text and HTML parts of a message.
+func parts(t *testing.T, data string) (*mail.Message, map[string]string) {
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	require.NoError(t, err)
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/alternative", mediaType)
+
+	found := make(map[string]string)
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := r.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		require.NoError(t, err)
+
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		found[contentType] = string(body)
+	}
+
+	return msg, found
+}
+
+type emailDB struct {
+	db.DB
+	emails map[string]*db.Email
+}
+
+func (f *emailDB) GetEmail(userID string) (*db.Email, error) {
+	if email, ok := f.emails[userID]; ok {
+		return email, nil
+	}
+	return &db.Email{UserID: userID}, nil
+}
+
+func TestEmail(t *testing.T) {
+	server := newSMTPServer(t)
+	fdb := &emailDB{emails: map[string]*db.Email{
+		"u1": {UserID: "u1", Address: "collector@example.com"},
+	}}
+
+	e := notify.NewEmail(fdb, server.config())
+
+	evt := event()
+	evt.Items[0].Image = "https://example.com/m1.jpg"
+	evt.Items[0].URL = "https://jp.mercari.com/item/m1"
+	assert.NoError(t, e.Notify(context.Background(), evt))
+
+	require.Len(t, server.messages, 1)
+	sent := server.messages[0]
+	assert.Equal(t, "FROM:<sendibot@example.com>", sent.from)
+	assert.Equal(t, []string{"TO:<collector@example.com>"}, sent.to)
+
+	msg, found := parts(t, sent.data)
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	assert.NoError(t, err)
+	assert.Equal(t, `🔔 1 new item(s) for "gameboy"`, subject)
+	assert.Equal(t, "collector@example.com", msg.Header.Get("To"))
+
+	assert.Contains(t, found["text/plain"], "ゲームボーイ")
+	assert.Contains(t, found["text/plain"], "¥7800 ($51) on Mercari")
+	assert.Contains(t, found["text/plain"], "https://jp.mercari.com/item/m1")
+	assert.Contains(t, found["text/html"], `<img src="https://example.com/m1.jpg"`)
+	assert.Contains(t, found["text/html"], evt.Items[0].SendicoLink())
+}
+
+func TestEmailUnverified(t *testing.T) {
+	server := newSMTPServer(t)
+	fdb := &emailDB{emails: map[string]*db.Email{
+		"u1": {UserID: "u1", PendingAddress: "collector@example.com", Code: "123456"},
+	}}
+
+	err := notify.NewEmail(fdb, server.config()).Notify(context.Background(), event())
+	assert.ErrorIs(t, err, notify.ErrUnreachable)
+	assert.Empty(t, server.messages)
+}
+
+func TestEmailRejected(t *testing.T) {
+	server := newSMTPServer(t)
+	server.reject = "gone@example.com"
+	fdb := &emailDB{emails: map[string]*db.Email{
+		"u1": {UserID: "u1", Address: "gone@example.com"},
+	}}
+
+	err := notify.NewEmail(fdb, server.config()).Notify(context.Background(), event())
+	assert.ErrorIs(t, err, notify.ErrUnreachable)
+}
+
+func TestEmailConfirmCode(t *testing.T) {
+	server := newSMTPServer(t)
+
+	err := notify.NewEmail(&emailDB{}, server.config()).SendConfirmCode(context.Background(), "new@example.com", "042042")
+	assert.NoError(t, err)
+
+	require.Len(t, server.messages, 1)
+	_, found := parts(t, server.messages[0].data)
+	assert.Contains(t, found["text/plain"], "042042")
+	assert.Contains(t, found["text/html"], "<strong>042042</strong>")
+}
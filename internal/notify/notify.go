@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/pkg/sendico"
+)
+
+var (
+	// ErrUnreachable means a notification can't be delivered no matter how many times it is retried, e.g. the user
+	// blocked the bot or the destination no longer exists.
+	ErrUnreachable = errors.New("recipient is unreachable")
+	// ErrUnknownNotifier means a subscription routes to a notifier that isn't configured.
+	ErrUnknownNotifier = errors.New("unknown notifier")
+	// ErrRejected means the destination refused a notification, e.g. a webhook answered with a client error. Retrying
+	// won't help until the user fixes the destination.
+	ErrRejected = errors.New("notification was rejected")
+)
+
+func NewUnreachableError(err error) error {
+	return fmt.Errorf("%w: %w", ErrUnreachable, err)
+}
+
+func NewRejectedError(err error) error {
+	return fmt.Errorf("%w: %w", ErrRejected, err)
+}
+
+func NewUnknownNotifierError(name string) error {
+	return fmt.Errorf("%w: %q", ErrUnknownNotifier, name)
+}
+
+// PartialError means a notifier delivered some of the events before failing on the rest, Sent holds the indexes of
+// the ones that went out so only the others are retried. Notifiers that send events separately can tell why each of
+// the others failed in Failed, events that aren't in it failed with Err.
+type PartialError struct {
+	Sent   []int
+	Err    error
+	Failed map[int]error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("delivered %d event(s) before failing: %v", len(e.Sent), e.Err)
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+// NewPartialError returns err as is if nothing was sent.
+func NewPartialError(sent []int, err error) error {
+	if len(sent) == 0 {
+		return err
+	}
+	return &PartialError{Sent: sent, Err: err}
+}
+
+// Reason is why items are being delivered, which changes how they are presented.
+type Reason string
+
+const (
+	// ReasonNew is used for items that were just found.
+	ReasonNew Reason = "new"
+	// ReasonDigest is used for items that were held for the user's digest.
+	ReasonDigest Reason = "digest"
+	// ReasonCatchUp is used for items that piled up while the bot was offline.
+	ReasonCatchUp Reason = "catchup"
+)
+
+// Event is a set of items found for a subscription.
+type Event struct {
+	Subscription db.Subscription
+	Term         db.Term
+	Items        []sendico.Item
+	// Notes are what was worked out about the items, by db.NoteKey, items may have none.
+	Notes  map[string]db.ItemNote
+	Reason Reason
+}
+
+// Notifier delivers events to a destination. Notify is given the events of a single user that share a reason, so they
+// can be sent together. Errors that will never succeed on retry should wrap ErrUnreachable, or ErrRejected if the
+// destination is still there, and a PartialError tells
+// which events went out if some did.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, events ...Event) error
+}
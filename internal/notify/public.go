@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrNotPublic means a URL points at an address that isn't on the public internet, like the bot's own host, its
+// private network or a cloud metadata service. URLs users give the bot are only allowed to reach public addresses.
+var ErrNotPublic = errors.New("address is not public")
+
+// sharedAddress is the carrier-grade NAT range, which some clouds put their metadata services in.
+var sharedAddress = netip.MustParsePrefix("100.64.0.0/10")
+
+// IsPublicAddr reports whether addr is on the public internet. Loopback, private, link-local (where cloud metadata
+// services live), shared, multicast and unspecified addresses are not.
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified() &&
+		!sharedAddress.Contains(addr) &&
+		!(addr.Is4() && addr.As4()[0] == 0)
+}
+
+// CheckPublicURL checks that raw is an http(s) URL whose host only resolves to public addresses.
+func CheckPublicURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" {
+		return errors.New("it must be an http(s) URL")
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return fmt.Errorf("couldn't look up %s", u.Hostname())
+	}
+
+	for _, addr := range addrs {
+		if !IsPublicAddr(addr) {
+			return fmt.Errorf("%s is not a public address", u.Hostname())
+		}
+	}
+
+	return nil
+}
+
+// NewPublicHTTPClient returns an HTTP client that only connects to public addresses. The address is checked when
+// dialing, so a host that resolved to a public address when its URL was checked can't be pointed inside later.
+func NewPublicHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !IsPublicAddr(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrNotPublic, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would be dialed instead of the host, and it is likely to be on the private network
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
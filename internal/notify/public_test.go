@@ -0,0 +1,64 @@
+package notify_test
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/robherley/sendibot/internal/notify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	tc := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.215.14", true},
+		{"2606:2800:21f:cb07:6820:80da:af6b:8b2c", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.100.100.200", false},
+		{"fd00:ec2::254", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.addr, func(t *testing.T) {
+			assert.Equal(t, tt.want, notify.IsPublicAddr(netip.MustParseAddr(tt.addr)))
+		})
+	}
+}
+
+func TestCheckPublicURL(t *testing.T) {
+	tc := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{name: "public address", url: "https://93.184.215.14/hook"},
+		{name: "not http", url: "ftp://93.184.215.14/hook", wantErr: true},
+		{name: "no host", url: "https:///hook", wantErr: true},
+		{name: "loopback", url: "http://127.0.0.1:8080/hook", wantErr: true},
+		{name: "localhost", url: "http://localhost/hook", wantErr: true},
+		{name: "metadata", url: "http://169.254.169.254/latest/meta-data", wantErr: true},
+		{name: "private", url: "http://[fd00::1]/hook", wantErr: true},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			err := notify.CheckPublicURL(context.Background(), tt.url)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/pkg/sendico"
+)
+
+const (
+	// MaxPushItems is the number of new items of an event published one by one, the rest are summed up in one more.
+	MaxPushItems = 5
+	// DefaultPushPriority is the priority of subscriptions that didn't pick one, ntfy's default.
+	DefaultPushPriority = 3
+)
+
+type PushOption func(*Push)
+
+// WithPushHTTPClient sets the HTTP client push servers are reached with. Without it, they may only be on public
+// addresses.
+func WithPushHTTPClient(httpClient *http.Client) PushOption {
+	return func(p *Push) {
+		p.httpClient = httpClient
+	}
+}
+
+// Push publishes events to the ntfy topic or Gotify app each user configured. New items are published one by one,
+// opening their Sendico page when clicked and with their image attached, digests are summed up per term.
+type Push struct {
+	db         db.DB
+	httpClient *http.Client
+}
+
+var _ Notifier = (*Push)(nil)
+
+func NewPush(db db.DB, opts ...PushOption) *Push {
+	p := &Push{
+		db:         db,
+		httpClient: NewPublicHTTPClient(10 * time.Second),
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+func (p *Push) Name() string {
+	return db.PushNotifier
+}
+
+// pushMessage is a notification, it is translated to what the user's server expects when published.
+type pushMessage struct {
+	Title    string
+	Message  string
+	Click    string
+	Attach   string
+	Priority int
+}
+
+// Notify publishes the events in order. An event counts as sent once all of its notifications are published, if one
+// fails the events before it are reported in a PartialError so they aren't published again.
+func (p *Push) Notify(ctx context.Context, events ...Event) error {
+	targets := make(map[string]*db.Push)
+	for i, event := range events {
+		target, ok := targets[event.Subscription.UserID]
+		if !ok {
+			var err error
+			if target, err = p.db.GetPush(event.Subscription.UserID); err != nil {
+				return NewPartialError(sent(i), err)
+			}
+			targets[event.Subscription.UserID] = target
+		}
+
+		if !target.IsSet() {
+			return NewPartialError(sent(i), NewUnreachableError(fmt.Errorf("user %q has no push server", event.Subscription.UserID)))
+		}
+
+		for _, msg := range pushMessages(event) {
+			if err := p.publish(ctx, target, msg); err != nil {
+				return NewPartialError(sent(i), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// SendTest publishes a notification to check that a push server is set up right.
+func (p *Push) SendTest(ctx context.Context, target *db.Push) error {
+	return p.publish(ctx, target, pushMessage{
+		Title:    "sendibot",
+		Message:  "👋 Push notifications are working, new items will show up here.",
+		Priority: DefaultPushPriority,
+	})
+}
+
+func pushMessages(event Event) []pushMessage {
+	if len(event.Items) == 0 {
+		return nil
+	}
+
+	priority := DefaultPushPriority
+	if event.Subscription.PushPriority != nil {
+		priority = *event.Subscription.PushPriority
+	}
+
+	if event.Reason != ReasonNew {
+		return []pushMessage{pushSummary(event, event.Items, priority)}
+	}
+
+	shown := event.Items[:min(len(event.Items), MaxPushItems)]
+	messages := make([]pushMessage, 0, len(shown)+1)
+	for _, item := range shown {
+		messages = append(messages, pushMessage{
+			Title:    fmt.Sprintf("🔔 New item for %q", event.Term.EN),
+			Message:  item.Name + "\n" + pushPrice(item),
+			Click:    item.SendicoLink(),
+			Attach:   item.Image,
+			Priority: priority,
+		})
+	}
+
+	if rest := event.Items[len(shown):]; len(rest) > 0 {
+		messages = append(messages, pushSummary(event, rest, priority))
+	}
+
+	return messages
+}
+
+// pushSummary sums items up in one notification, listing the cheapest few and opening the cheapest when clicked.
+func pushSummary(event Event, items []sendico.Item, priority int) pushMessage {
+	cheapest := slices.Clone(items)
+	slices.SortStableFunc(cheapest, func(a, b sendico.Item) int {
+		return a.PriceYen - b.PriceYen
+	})
+
+	lines := make([]string, 0, MaxPushItems+1)
+	for _, item := range cheapest[:min(len(cheapest), MaxPushItems)] {
+		lines = append(lines, "- "+item.Name+" "+pushPrice(item))
+	}
+	if len(cheapest) > MaxPushItems {
+		lines = append(lines, fmt.Sprintf("…and %d more", len(cheapest)-MaxPushItems))
+	}
+
+	return pushMessage{
+		Title:    fmt.Sprintf("🔔 %d new item(s) for %q", len(items), event.Term.EN),
+		Message:  strings.Join(lines, "\n"),
+		Click:    cheapest[0].SendicoLink(),
+		Attach:   cheapest[0].Image,
+		Priority: priority,
+	}
+}
+
+func pushPrice(item sendico.Item) string {
+	return fmt.Sprintf("¥%d ($%d) on %s", item.PriceYen, item.PriceUSD, item.Shop.Name())
+}
+
+type ntfyMessage struct {
+	Topic    string `json:"topic"`
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+	Click    string `json:"click,omitempty"`
+	Attach   string `json:"attach,omitempty"`
+}
+
+type gotifyMessage struct {
+	Title    string         `json:"title"`
+	Message  string         `json:"message"`
+	Priority int            `json:"priority"`
+	Extras   map[string]any `json:"extras,omitempty"`
+}
+
+func (p *Push) publish(ctx context.Context, target *db.Push, msg pushMessage) error {
+	var (
+		endpoint string
+		body     any
+		header   = make(http.Header)
+	)
+
+	switch target.Kind {
+	case db.PushNtfy:
+		endpoint = strings.TrimSuffix(target.ServerURL, "/")
+		body = ntfyMessage{
+			Topic:    target.Topic,
+			Title:    msg.Title,
+			Message:  msg.Message,
+			Priority: msg.Priority,
+			Click:    msg.Click,
+			Attach:   msg.Attach,
+		}
+		if target.Token != "" {
+			header.Set("Authorization", "Bearer "+target.Token)
+		}
+	case db.PushGotify:
+		endpoint = strings.TrimSuffix(target.ServerURL, "/") + "/message"
+		notification := map[string]any{}
+		if msg.Click != "" {
+			notification["click"] = map[string]string{"url": msg.Click}
+		}
+		if msg.Attach != "" {
+			notification["bigImageUrl"] = msg.Attach
+		}
+		body = gotifyMessage{
+			Title:   msg.Title,
+			Message: msg.Message,
+			// gotify priorities go up to 10
+			Priority: msg.Priority * 2,
+			Extras:   map[string]any{"client::notification": notification},
+		}
+		header.Set("X-Gotify-Key", target.Token)
+	default:
+		return NewUnreachableError(fmt.Errorf("unknown push server kind %q", target.Kind))
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return NewUnreachableError(err)
+	}
+
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "sendibot (https://github.com/robherley/sendibot)")
+
+	res, err := p.httpClient.Do(req)
+	if errors.Is(err, ErrNotPublic) {
+		return NewUnreachableError(err)
+	}
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return nil
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusNotFound:
+		return NewUnreachableError(fmt.Errorf("push server responded with status code: %d", res.StatusCode))
+	default:
+		return fmt.Errorf("push server responded with status code: %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,160 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/notify"
+	"github.com/robherley/sendibot/pkg/sendico"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type pushDB struct {
+	db.DB
+	pushes map[string]*db.Push
+}
+
+func (f *pushDB) GetPush(userID string) (*db.Push, error) {
+	if push, ok := f.pushes[userID]; ok {
+		return push, nil
+	}
+	return &db.Push{UserID: userID}, nil
+}
+
+// pushServer is a stand-in push server that records the JSON bodies and headers it is sent.
+func pushServer(t *testing.T, status int) (*httptest.Server, *[]map[string]any, *[]*http.Request) {
+	var (
+		bodies   []map[string]any
+		requests []*http.Request
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies = append(bodies, body)
+		requests = append(requests, r)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &bodies, &requests
+}
+
+func TestPushNtfy(t *testing.T) {
+	server, bodies, requests := pushServer(t, http.StatusOK)
+	fdb := &pushDB{pushes: map[string]*db.Push{
+		"u1": {UserID: "u1", Kind: db.PushNtfy, ServerURL: server.URL + "/", Topic: "sendibot", Token: "tk_secret"},
+	}}
+
+	priority := 5
+	evt := event()
+	evt.Subscription.PushPriority = &priority
+	evt.Items[0].Image = "https://example.com/m1.jpg"
+
+	assert.NoError(t, notify.NewPush(fdb, notify.WithPushHTTPClient(http.DefaultClient)).Notify(context.Background(), evt))
+
+	require.Len(t, *bodies, 1)
+	body := (*bodies)[0]
+	assert.Equal(t, "/", (*requests)[0].URL.Path)
+	assert.Equal(t, "Bearer tk_secret", (*requests)[0].Header.Get("Authorization"))
+	assert.Equal(t, "sendibot", body["topic"])
+	assert.Equal(t, `🔔 New item for "gameboy"`, body["title"])
+	assert.Equal(t, "ゲームボーイ\n¥7800 ($51) on Mercari", body["message"])
+	assert.Equal(t, float64(5), body["priority"])
+	assert.Equal(t, evt.Items[0].SendicoLink(), body["click"])
+	assert.Equal(t, "https://example.com/m1.jpg", body["attach"])
+}
+
+func TestPushGotify(t *testing.T) {
+	server, bodies, requests := pushServer(t, http.StatusOK)
+	fdb := &pushDB{pushes: map[string]*db.Push{
+		"u1": {UserID: "u1", Kind: db.PushGotify, ServerURL: server.URL, Token: "app_token"},
+	}}
+
+	evt := event()
+	evt.Reason = notify.ReasonDigest
+	evt.Items = append(evt.Items, sendico.Item{Shop: sendico.Mercari, Code: "m2", Name: "cheap", PriceYen: 100, Image: "https://example.com/m2.jpg"})
+
+	assert.NoError(t, notify.NewPush(fdb, notify.WithPushHTTPClient(http.DefaultClient)).Notify(context.Background(), evt))
+
+	// digests are summed up in one notification that opens the cheapest item
+	require.Len(t, *bodies, 1)
+	body := (*bodies)[0]
+	assert.Equal(t, "/message", (*requests)[0].URL.Path)
+	assert.Equal(t, "app_token", (*requests)[0].Header.Get("X-Gotify-Key"))
+	assert.Equal(t, `🔔 2 new item(s) for "gameboy"`, body["title"])
+	assert.Equal(t, float64(notify.DefaultPushPriority*2), body["priority"])
+
+	notification := body["extras"].(map[string]any)["client::notification"].(map[string]any)
+	assert.Equal(t, evt.Items[1].SendicoLink(), notification["click"].(map[string]any)["url"])
+	assert.Equal(t, "https://example.com/m2.jpg", notification["bigImageUrl"])
+}
+
+func TestPushManyItems(t *testing.T) {
+	server, bodies, _ := pushServer(t, http.StatusOK)
+	fdb := &pushDB{pushes: map[string]*db.Push{
+		"u1": {UserID: "u1", Kind: db.PushNtfy, ServerURL: server.URL, Topic: "sendibot"},
+	}}
+
+	evt := event()
+	for i := 0; i < notify.MaxPushItems+2; i++ {
+		evt.Items = append(evt.Items, evt.Items[0])
+	}
+
+	assert.NoError(t, notify.NewPush(fdb, notify.WithPushHTTPClient(http.DefaultClient)).Notify(context.Background(), evt))
+	require.Len(t, *bodies, notify.MaxPushItems+1)
+	assert.Equal(t, `🔔 3 new item(s) for "gameboy"`, (*bodies)[notify.MaxPushItems]["title"])
+}
+
+func TestPushPartial(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	fdb := &pushDB{pushes: map[string]*db.Push{
+		"u1": {UserID: "u1", Kind: db.PushNtfy, ServerURL: server.URL, Topic: "sendibot"},
+	}}
+
+	second := event()
+	second.Items = append(second.Items, sendico.Item{Shop: sendico.Mercari, Code: "m2", Name: "cheap", PriceYen: 100})
+
+	err := notify.NewPush(fdb, notify.WithPushHTTPClient(http.DefaultClient)).Notify(context.Background(), event(), second, event())
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, notify.ErrUnreachable)
+
+	// the second event's first notification went out, but it only counts once all of them did
+	var partial *notify.PartialError
+	require.ErrorAs(t, err, &partial)
+	assert.Equal(t, []int{0}, partial.Sent)
+	assert.Equal(t, 3, requests)
+}
+
+func TestPushUnreachable(t *testing.T) {
+	server, _, _ := pushServer(t, http.StatusUnauthorized)
+	fdb := &pushDB{pushes: map[string]*db.Push{
+		"u1": {UserID: "u1", Kind: db.PushNtfy, ServerURL: server.URL, Topic: "sendibot"},
+	}}
+
+	err := notify.NewPush(fdb, notify.WithPushHTTPClient(http.DefaultClient)).Notify(context.Background(), event())
+	assert.ErrorIs(t, err, notify.ErrUnreachable)
+
+	err = notify.NewPush(&pushDB{}, notify.WithPushHTTPClient(http.DefaultClient)).Notify(context.Background(), event())
+	assert.ErrorIs(t, err, notify.ErrUnreachable)
+
+	// the test server is on loopback, which push servers can't be on
+	err = notify.NewPush(fdb).Notify(context.Background(), event())
+	assert.ErrorIs(t, err, notify.ErrUnreachable)
+	assert.ErrorIs(t, err, notify.ErrNotPublic)
+}
@@ -0,0 +1,278 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/pkg/sendico"
+)
+
+const (
+	// WebhookVersion is the version of the webhook payload, it is bumped on breaking changes.
+	WebhookVersion = 1
+	// WebhookAttempts is the number of times a webhook request is tried before giving up until the next dispatch.
+	WebhookAttempts = 3
+	// DefaultWebhookBackoff is the delay before the first retry of a webhook request, it doubles on every attempt.
+	DefaultWebhookBackoff = 2 * time.Second
+
+	HeaderSignature = "X-Sendibot-Signature"
+	HeaderNonce     = "X-Sendibot-Nonce"
+	HeaderTimestamp = "X-Sendibot-Timestamp"
+)
+
+// WebhookPayload is the JSON body posted to webhooks, one per event.
+type WebhookPayload struct {
+	Version      int                 `json:"version"`
+	Reason       Reason              `json:"reason"`
+	Subscription WebhookSubscription `json:"subscription"`
+	Term         WebhookTerm         `json:"term"`
+	Items        []WebhookItem       `json:"items"`
+}
+
+type WebhookSubscription struct {
+	ID       string `json:"id"`
+	UserID   string `json:"user_id"`
+	MinPrice *int   `json:"min_price"`
+	MaxPrice *int   `json:"max_price"`
+}
+
+type WebhookTerm struct {
+	EN string `json:"en"`
+	JP string `json:"jp"`
+}
+
+type WebhookItem struct {
+	Shop       string `json:"shop"`
+	Code       string `json:"code"`
+	Name       string `json:"name"`
+	PriceYen   int    `json:"price_yen"`
+	PriceUSD   int    `json:"price_usd"`
+	URL        string `json:"url"`
+	SendicoURL string `json:"sendico_url"`
+	Image      string `json:"image"`
+	// DealScore is how far below the usual price the item is, in percent, for subscriptions that only want deals.
+	DealScore *int `json:"deal_score,omitempty"`
+	// FollowedSeller is set if the item is from a seller the subscriber follows.
+	FollowedSeller bool `json:"followed_seller,omitempty"`
+	// Irrelevance is how likely the item is irrelevant, in percent, going by the subscriber's votes. It is only set for
+	// items that are likely irrelevant.
+	Irrelevance int `json:"irrelevance,omitempty"`
+	// Variants are duplicates of the item, on other shops or relisted, that were collapsed into it.
+	Variants []WebhookItem `json:"variants,omitempty"`
+}
+
+func NewWebhookPayload(event Event) WebhookPayload {
+	items := make([]WebhookItem, 0, len(event.Items))
+	for _, item := range event.Items {
+		webhookItem := newWebhookItem(item)
+		note := event.Notes[db.NoteKey(item)]
+		if note.Deal != nil {
+			webhookItem.DealScore = note.Deal.Score
+		}
+		webhookItem.FollowedSeller = note.Followed
+		webhookItem.Irrelevance = note.Irrelevance
+		for _, variant := range note.Variants {
+			webhookItem.Variants = append(webhookItem.Variants, newWebhookItem(variant))
+		}
+		items = append(items, webhookItem)
+	}
+
+	return WebhookPayload{
+		Version: WebhookVersion,
+		Reason:  event.Reason,
+		Subscription: WebhookSubscription{
+			ID:       event.Subscription.ID,
+			UserID:   event.Subscription.UserID,
+			MinPrice: event.Subscription.MinPrice,
+			MaxPrice: event.Subscription.MaxPrice,
+		},
+		Term: WebhookTerm{
+			EN: event.Term.EN,
+			JP: event.Term.JP,
+		},
+		Items: items,
+	}
+}
+
+type WebhookOption func(*Webhook)
+
+// WithWebhookHTTPClient sets the HTTP client for every webhook URL. Without it, subscriptions' own URLs may only reach
+// public addresses.
+func WithWebhookHTTPClient(httpClient *http.Client) WebhookOption {
+	return func(w *Webhook) {
+		w.httpClient = httpClient
+		w.publicClient = httpClient
+	}
+}
+
+func WithWebhookBackoff(backoff time.Duration) WebhookOption {
+	return func(w *Webhook) {
+		w.backoff = backoff
+	}
+}
+
+// Webhook posts events as JSON to a URL, which is set per subscription or falls back to a global one. Requests are
+// signed the same way Sendico API requests are, an HMAC-SHA256 of the URL path, body, nonce and timestamp, keyed with
+// the subscription's own secret or the global one for the global URL.
+type Webhook struct {
+	defaultURL string
+	secret     string
+	httpClient *http.Client
+	// publicClient is used for subscriptions' own URLs, which users set and so can't reach the bot's network
+	publicClient *http.Client
+	backoff      time.Duration
+}
+
+var _ Notifier = (*Webhook)(nil)
+
+func NewWebhook(defaultURL, secret string, opts ...WebhookOption) *Webhook {
+	w := &Webhook{
+		defaultURL:   defaultURL,
+		secret:       secret,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+		publicClient: NewPublicHTTPClient(10 * time.Second),
+		backoff:      DefaultWebhookBackoff,
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+func (w *Webhook) Name() string {
+	return db.WebhookNotifier
+}
+
+// Notify posts each event to its subscription's webhook. It stops at the first event that fails, the ones before it
+// are reported as sent.
+func (w *Webhook) Notify(ctx context.Context, events ...Event) error {
+	for i, event := range events {
+		target, secret, client := w.defaultURL, w.secret, w.httpClient
+		if event.Subscription.WebhookURL != nil {
+			target, client = *event.Subscription.WebhookURL, w.publicClient
+			if event.Subscription.WebhookSecret == nil {
+				// subscriptions from before secrets were per subscription have to subscribe again to get one
+				return NewPartialError(sent(i), NewUnreachableError(fmt.Errorf("no webhook secret for subscription %q", event.Subscription.ID)))
+			}
+			secret = *event.Subscription.WebhookSecret
+		}
+
+		if target == "" {
+			return NewPartialError(sent(i), NewUnreachableError(fmt.Errorf("no webhook url for subscription %q", event.Subscription.ID)))
+		}
+
+		if err := w.post(ctx, client, target, secret, NewWebhookPayload(event)); err != nil {
+			return NewPartialError(sent(i), err)
+		}
+	}
+
+	return nil
+}
+
+func (w *Webhook) post(ctx context.Context, client *http.Client, target, secret string, payload WebhookPayload) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return NewUnreachableError(err)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	backoff := w.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := w.send(ctx, client, u, secret, body)
+		if err == nil || !retry || attempt == WebhookAttempts {
+			return err
+		}
+
+		slog.Warn("webhook request failed, retrying", "component", "notify.webhook", "err", err, "attempt", attempt, "retry", backoff)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// send makes one signed request, it returns whether a failure is worth retrying.
+func (w *Webhook) send(ctx context.Context, client *http.Client, u *url.URL, secret string, body []byte) (bool, error) {
+	nonce, timestamp := uuid.New().String(), time.Now().Unix()
+	signature, err := sendico.Sign(secret, u.Path, json.RawMessage(body), nonce, timestamp)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return false, NewUnreachableError(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "sendibot (https://github.com/robherley/sendibot)")
+	req.Header.Set(HeaderSignature, signature)
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+
+	res, err := client.Do(req)
+	if errors.Is(err, ErrNotPublic) {
+		return false, NewUnreachableError(err)
+	}
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return false, nil
+	case res.StatusCode == http.StatusGone:
+		return false, NewUnreachableError(fmt.Errorf("webhook responded with status code: %d", res.StatusCode))
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusRequestTimeout || res.StatusCode >= 500:
+		return true, fmt.Errorf("webhook responded with status code: %d", res.StatusCode)
+	case res.StatusCode >= 400:
+		// the request won't be any different next time, e.g. the secret is wrong or the payload isn't understood
+		return false, NewRejectedError(fmt.Errorf("webhook responded with status code: %d", res.StatusCode))
+	default:
+		return false, fmt.Errorf("webhook responded with status code: %d", res.StatusCode)
+	}
+}
+
+// sent returns the indexes of the first n events.
+func sent(n int) []int {
+	indexes := make([]int, n)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return indexes
+}
+
+func newWebhookItem(item sendico.Item) WebhookItem {
+	return WebhookItem{
+		Shop:       item.Shop.Identifier(),
+		Code:       item.Code,
+		Name:       item.Name,
+		PriceYen:   item.PriceYen,
+		PriceUSD:   item.PriceUSD,
+		URL:        item.URL,
+		SendicoURL: item.SendicoLink(),
+		Image:      item.Image,
+	}
+}
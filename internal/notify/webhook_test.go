@@ -0,0 +1,182 @@
+package notify_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/notify"
+	"github.com/robherley/sendibot/pkg/sendico"
+	"github.com/stretchr/testify/assert"
+)
+
+const secret = "correct horse battery staple"
+
+func event() notify.Event {
+	return notify.Event{
+		Subscription: db.Subscription{ID: "s1", UserID: "u1"},
+		Term:         db.Term{EN: "gameboy", JP: "ゲームボーイ"},
+		Items: []sendico.Item{
+			{Shop: sendico.Mercari, Code: "m1", Name: "ゲームボーイ", PriceYen: 7800, PriceUSD: 51},
+		},
+		Reason: notify.ReasonNew,
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var (
+		attempts   int
+		payload    notify.WebhookPayload
+		wantSecret = secret
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		timestamp, err := strconv.ParseInt(r.Header.Get(notify.HeaderTimestamp), 10, 64)
+		assert.NoError(t, err)
+
+		signed := fmt.Sprintf(`{"url":%q,"body":%s,"nonce":%q,"timestamp":%d}`, r.URL.Path, body, r.Header.Get(notify.HeaderNonce), timestamp)
+		mac := hmac.New(sha256.New, []byte(wantSecret))
+		mac.Write([]byte(signed))
+		assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), r.Header.Get(notify.HeaderSignature))
+
+		assert.NoError(t, json.Unmarshal(body, &payload))
+	}))
+	defer server.Close()
+
+	// the test server is on loopback, which subscriptions' own URLs can't reach otherwise
+	webhook := notify.NewWebhook(server.URL+"/hooks/sendibot", secret, notify.WithWebhookBackoff(time.Millisecond), notify.WithWebhookHTTPClient(server.Client()))
+	assert.NoError(t, webhook.Notify(context.Background(), event()))
+
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, notify.WebhookVersion, payload.Version)
+	assert.Equal(t, notify.ReasonNew, payload.Reason)
+	assert.Equal(t, "s1", payload.Subscription.ID)
+	assert.Equal(t, "ゲームボーイ", payload.Term.JP)
+	if assert.Len(t, payload.Items, 1) {
+		assert.Equal(t, "mercari", payload.Items[0].Shop)
+		assert.Equal(t, 7800, payload.Items[0].PriceYen)
+		assert.Equal(t, "https://sendico.com/shop/mercari/catalog/m1", payload.Items[0].SendicoURL)
+	}
+
+	t.Run("subscription url is signed with its own secret", func(t *testing.T) {
+		e := event()
+		own, ownSecret := server.URL+"/own", "own secret"
+		e.Subscription.WebhookURL, e.Subscription.WebhookSecret = &own, &ownSecret
+		wantSecret = ownSecret
+
+		assert.NoError(t, webhook.Notify(context.Background(), e))
+		assert.Equal(t, 3, attempts)
+	})
+}
+
+func TestWebhookErrors(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/ok":
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	webhook := notify.NewWebhook(server.URL+"/default", secret, notify.WithWebhookBackoff(time.Millisecond), notify.WithWebhookHTTPClient(server.Client()))
+
+	t.Run("retries are capped", func(t *testing.T) {
+		paths = nil
+		err := webhook.Notify(context.Background(), event())
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, notify.ErrUnreachable)
+		assert.Equal(t, []string{"/default", "/default", "/default"}, paths)
+	})
+
+	t.Run("subscription url gone", func(t *testing.T) {
+		paths = nil
+		e := event()
+		gone, goneSecret := server.URL+"/gone", "gone secret"
+		e.Subscription.WebhookURL, e.Subscription.WebhookSecret = &gone, &goneSecret
+
+		err := webhook.Notify(context.Background(), e)
+		assert.ErrorIs(t, err, notify.ErrUnreachable)
+		assert.Equal(t, []string{"/gone"}, paths)
+	})
+
+	t.Run("subscription url refuses", func(t *testing.T) {
+		paths = nil
+		e := event()
+		forbidden, forbiddenSecret := server.URL+"/forbidden", "forbidden secret"
+		e.Subscription.WebhookURL, e.Subscription.WebhookSecret = &forbidden, &forbiddenSecret
+
+		// client errors aren't retried
+		err := webhook.Notify(context.Background(), e)
+		assert.ErrorIs(t, err, notify.ErrRejected)
+		assert.NotErrorIs(t, err, notify.ErrUnreachable)
+		assert.Equal(t, []string{"/forbidden"}, paths)
+	})
+
+	t.Run("events before a failure are sent", func(t *testing.T) {
+		paths = nil
+		gone, goneSecret := server.URL+"/gone", "gone secret"
+		first, second := event(), event()
+		second.Subscription.WebhookURL, second.Subscription.WebhookSecret = &gone, &goneSecret
+
+		err := notify.NewWebhook(server.URL+"/ok", secret, notify.WithWebhookHTTPClient(server.Client())).Notify(context.Background(), first, second)
+		var partial *notify.PartialError
+		if assert.ErrorAs(t, err, &partial) {
+			assert.Equal(t, []int{0}, partial.Sent)
+		}
+		assert.ErrorIs(t, err, notify.ErrUnreachable)
+	})
+
+	t.Run("subscription url without a secret", func(t *testing.T) {
+		paths = nil
+		e := event()
+		unsigned := server.URL + "/unsigned"
+		e.Subscription.WebhookURL = &unsigned
+
+		err := webhook.Notify(context.Background(), e)
+		assert.ErrorIs(t, err, notify.ErrUnreachable)
+		assert.Empty(t, paths)
+	})
+
+	t.Run("subscription url not public", func(t *testing.T) {
+		paths = nil
+		e := event()
+		private, privateSecret := server.URL+"/private", "private secret"
+		e.Subscription.WebhookURL, e.Subscription.WebhookSecret = &private, &privateSecret
+
+		err := notify.NewWebhook("", secret).Notify(context.Background(), e)
+		assert.ErrorIs(t, err, notify.ErrUnreachable)
+		assert.ErrorIs(t, err, notify.ErrNotPublic)
+		assert.Empty(t, paths)
+	})
+
+	t.Run("no url", func(t *testing.T) {
+		err := notify.NewWebhook("", secret).Notify(context.Background(), event())
+		assert.ErrorIs(t, err, notify.ErrUnreachable)
+	})
+}
@@ -0,0 +1,77 @@
+package pricing
+
+import (
+	"math"
+	"slices"
+)
+
+// Stats summarizes a set of prices.
+type Stats struct {
+	Count  int
+	Min    int
+	P25    int
+	Median int
+	P75    int
+	Max    int
+}
+
+// Summarize returns the stats of prices, all zero if there are none.
+func Summarize(prices []int) Stats {
+	if len(prices) == 0 {
+		return Stats{}
+	}
+
+	sorted := slices.Clone(prices)
+	slices.Sort(sorted)
+
+	return Stats{
+		Count:  len(sorted),
+		Min:    sorted[0],
+		P25:    percentile(sorted, 0.25),
+		Median: percentile(sorted, 0.5),
+		P75:    percentile(sorted, 0.75),
+		Max:    sorted[len(sorted)-1],
+	}
+}
+
+// percentile interpolates between the closest ranks of sorted prices, which must not be empty.
+func percentile(sorted []int, p float64) int {
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	weight := rank - float64(lower)
+	return int(math.Round(float64(sorted[lower])*(1-weight) + float64(sorted[upper])*weight))
+}
+
+// Bucket is a range of prices [Low, High) and how many prices fall in it, the last bucket includes High.
+type Bucket struct {
+	Low   int
+	High  int
+	Count int
+}
+
+// Histogram splits the range of prices into n equally wide buckets. It returns fewer if the range is too narrow to
+// split, one if all prices are the same, and none if there are no prices.
+func Histogram(prices []int, n int) []Bucket {
+	if len(prices) == 0 || n < 1 {
+		return nil
+	}
+
+	low, high := slices.Min(prices), slices.Max(prices)
+	if low == high {
+		return []Bucket{{Low: low, High: high, Count: len(prices)}}
+	}
+
+	n = min(n, high-low)
+	buckets := make([]Bucket, n)
+	for i := range buckets {
+		buckets[i] = Bucket{Low: low + (high-low)*i/n, High: low + (high-low)*(i+1)/n}
+	}
+
+	for _, price := range prices {
+		i := min((price-low)*n/(high-low), n-1)
+		buckets[i].Count++
+	}
+
+	return buckets
+}
@@ -0,0 +1,93 @@
+package pricing_test
+
+import (
+	"testing"
+
+	"github.com/robherley/sendibot/internal/pricing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarize(t *testing.T) {
+	tc := []struct {
+		name   string
+		prices []int
+		want   pricing.Stats
+	}{
+		{
+			name: "no prices",
+			want: pricing.Stats{},
+		},
+		{
+			name:   "one price",
+			prices: []int{1200},
+			want:   pricing.Stats{Count: 1, Min: 1200, P25: 1200, Median: 1200, P75: 1200, Max: 1200},
+		},
+		{
+			name:   "odd count",
+			prices: []int{5000, 1000, 3000, 2000, 4000},
+			want:   pricing.Stats{Count: 5, Min: 1000, P25: 2000, Median: 3000, P75: 4000, Max: 5000},
+		},
+		{
+			name:   "interpolates between ranks",
+			prices: []int{1000, 2000, 3000, 4000},
+			want:   pricing.Stats{Count: 4, Min: 1000, P25: 1750, Median: 2500, P75: 3250, Max: 4000},
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, pricing.Summarize(tt.prices))
+		})
+	}
+}
+
+func TestSummarizeDoesNotSort(t *testing.T) {
+	prices := []int{3, 1, 2}
+	pricing.Summarize(prices)
+	assert.Equal(t, []int{3, 1, 2}, prices)
+}
+
+func TestHistogram(t *testing.T) {
+	tc := []struct {
+		name   string
+		prices []int
+		n      int
+		want   []pricing.Bucket
+	}{
+		{
+			name: "no prices",
+			n:    4,
+		},
+		{
+			name:   "same price",
+			prices: []int{500, 500},
+			n:      4,
+			want:   []pricing.Bucket{{Low: 500, High: 500, Count: 2}},
+		},
+		{
+			name:   "even buckets",
+			prices: []int{1000, 1500, 1999, 2000, 2500, 3999, 4000},
+			n:      3,
+			want: []pricing.Bucket{
+				{Low: 1000, High: 2000, Count: 3},
+				{Low: 2000, High: 3000, Count: 2},
+				{Low: 3000, High: 4000, Count: 2},
+			},
+		},
+		{
+			name:   "range narrower than the buckets",
+			prices: []int{10, 11, 12},
+			n:      5,
+			want: []pricing.Bucket{
+				{Low: 10, High: 11, Count: 1},
+				{Low: 11, High: 12, Count: 2},
+			},
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, pricing.Histogram(tt.prices, tt.n))
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package relevance
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/dedup"
+)
+
+const (
+	// MinVotes is the number of votes needed before a model is trusted.
+	MinVotes = 10
+	// MinVotesEach is the number of votes of each kind needed before a model is trusted, it can't tell what is wanted
+	// from only down votes.
+	MinVotesEach = 3
+	// MarkThreshold is how likely an item has to be voted down for it to be marked as likely irrelevant.
+	MarkThreshold = 0.7
+	// SuppressThreshold is how likely an item has to be voted down for it to not be sent at all.
+	SuppressThreshold = 0.95
+	// MinKeywordVotes is the number of down voted items a word has to be in to be suggested as a negative keyword.
+	MinKeywordVotes = 2
+)
+
+// Model is a naive Bayes classifier of item names into relevant (voted up) and irrelevant (voted down), learned from a
+// subscription's votes.
+type Model struct {
+	// counts are the number of names of each class, up and down, each n-gram is in.
+	counts [2]map[string]int
+	totals [2]int
+	vocab  map[string]struct{}
+}
+
+const (
+	up = iota
+	down
+)
+
+// Train returns a model trained on a subscription's votes, or false if there aren't enough votes yet.
+func Train(feedback []db.Feedback) (*Model, bool) {
+	var votes [2]int
+	for _, f := range feedback {
+		votes[class(f.Vote)]++
+	}
+	if len(feedback) < MinVotes || votes[up] < MinVotesEach || votes[down] < MinVotesEach {
+		return nil, false
+	}
+
+	m := &Model{
+		counts: [2]map[string]int{make(map[string]int), make(map[string]int)},
+		vocab:  make(map[string]struct{}),
+	}
+	for _, f := range feedback {
+		c := class(f.Vote)
+		for gram := range ngrams(f.Name) {
+			m.counts[c][gram]++
+			m.totals[c]++
+			m.vocab[gram] = struct{}{}
+		}
+	}
+
+	return m, true
+}
+
+// Irrelevance is how likely an item is to be voted down going by its name, from 0 to 1. Both kinds of vote are taken
+// to be as likely up front, users tend to vote down far more than up, so only the name decides.
+func (m *Model) Irrelevance(name string) float64 {
+	var scores [2]float64
+	for gram := range ngrams(name) {
+		if _, ok := m.vocab[gram]; !ok {
+			continue
+		}
+		for c := range scores {
+			// laplace smoothing, so an n-gram never seen in one class doesn't rule it out
+			scores[c] += math.Log(float64(m.counts[c][gram]+1) / float64(m.totals[c]+len(m.vocab)))
+		}
+	}
+
+	return 1 / (1 + math.Exp(scores[up]-scores[down]))
+}
+
+func class(vote db.Vote) int {
+	if vote == db.VoteDown {
+		return down
+	}
+	return up
+}
+
+// ngrams returns the set of character bigrams and trigrams of each word of a normalized name. Words are padded with
+// spaces so n-grams at their edges are told apart from ones within them.
+func ngrams(name string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, word := range strings.Fields(dedup.Normalize(name)) {
+		runes := []rune(" " + word + " ")
+		for n := 2; n <= 3; n++ {
+			for i := 0; i+n <= len(runes); i++ {
+				set[string(runes[i:i+n])] = struct{}{}
+			}
+		}
+	}
+	return set
+}
+
+// Keywords suggests up to n words to exclude from a subscription: words in at least MinKeywordVotes down voted names
+// and no up voted ones, the most down voted first.
+func Keywords(feedback []db.Feedback, n int) []string {
+	var counts [2]map[string]int
+	counts[up], counts[down] = make(map[string]int), make(map[string]int)
+	for _, f := range feedback {
+		for word := range words(f.Name) {
+			counts[class(f.Vote)][word]++
+		}
+	}
+
+	var keywords []string
+	for word, count := range counts[down] {
+		if count >= MinKeywordVotes && counts[up][word] == 0 {
+			keywords = append(keywords, word)
+		}
+	}
+
+	slices.SortFunc(keywords, func(a, b string) int {
+		return cmp.Or(counts[down][b]-counts[down][a], strings.Compare(a, b))
+	})
+
+	return keywords[:min(len(keywords), n)]
+}
+
+// words returns the set of words of a normalized name, leaving out single characters and numbers which are mostly
+// noise, like sizes and counts.
+func words(name string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, word := range strings.Fields(dedup.Normalize(name)) {
+		if len([]rune(word)) < 2 || strings.IndexFunc(word, func(r rune) bool { return !unicode.IsNumber(r) }) < 0 {
+			continue
+		}
+		set[word] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,74 @@
+package relevance_test
+
+import (
+	"testing"
+
+	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/relevance"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func votes(vote db.Vote, names ...string) []db.Feedback {
+	feedback := make([]db.Feedback, 0, len(names))
+	for _, name := range names {
+		feedback = append(feedback, db.Feedback{Name: name, Vote: vote})
+	}
+	return feedback
+}
+
+var feedback = append(
+	votes(db.VoteUp,
+		"ゲームボーイアドバンスSP 本体 アズライト",
+		"ゲームボーイアドバンスSP 本体 動作確認済み",
+		"【美品】ゲームボーイアドバンスSP 本体 充電器付き",
+		"GBA SP 本体 パールブルー",
+		"ゲームボーイアドバンスSP 本体 箱説付き",
+	),
+	votes(db.VoteDown,
+		"ゲームボーイアドバンスSP ジャンク 部品取り",
+		"【ジャンク】ゲームボーイアドバンスSP 画面割れ",
+		"ゲームボーイアドバンスSP 充電器のみ",
+		"GBA SP 外装 シェル 交換用",
+		"ゲームボーイアドバンスSP ソフト 5本 セット",
+	)...,
+)
+
+func TestTrain(t *testing.T) {
+	t.Run("not enough votes", func(t *testing.T) {
+		_, ok := relevance.Train(feedback[:relevance.MinVotes-1])
+		assert.False(t, ok)
+	})
+
+	t.Run("not enough of each vote", func(t *testing.T) {
+		lopsided := append(votes(db.VoteUp, "a", "b"), votes(db.VoteDown, "c", "d", "e", "f", "g", "h", "i", "j")...)
+		_, ok := relevance.Train(lopsided)
+		assert.False(t, ok)
+	})
+
+	t.Run("enough votes", func(t *testing.T) {
+		_, ok := relevance.Train(feedback)
+		assert.True(t, ok)
+	})
+}
+
+func TestModelIrrelevance(t *testing.T) {
+	model, ok := relevance.Train(feedback)
+	require.True(t, ok)
+
+	assert.Greater(t, model.Irrelevance("ジャンク ゲームボーイアドバンスSP 部品取りに"), relevance.MarkThreshold)
+	assert.Less(t, model.Irrelevance("ゲームボーイアドバンスSP 本体 オニキス"), 0.5)
+	// nothing in common with any vote, so it can't tell
+	assert.InDelta(t, 0.5, model.Irrelevance("ポケモンカード"), 0.001)
+}
+
+func TestKeywords(t *testing.T) {
+	more := append(feedback, votes(db.VoteDown,
+		"ジャンク GBA SP 通電せず",
+		"GBA SP 外装 ジャンク",
+	)...)
+
+	assert.Equal(t, []string{"ジャンク", "外装"}, relevance.Keywords(more, 3))
+	assert.Equal(t, []string{"ジャンク"}, relevance.Keywords(more, 1))
+	assert.Empty(t, relevance.Keywords(feedback[:5], 3))
+}
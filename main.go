@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -14,13 +16,31 @@ import (
 	"github.com/lmittmann/tint"
 	"github.com/robherley/sendibot/internal/bot"
 	"github.com/robherley/sendibot/internal/db"
+	"github.com/robherley/sendibot/internal/dedup"
+	"github.com/robherley/sendibot/internal/feed"
 	"github.com/robherley/sendibot/internal/looper"
+	"github.com/robherley/sendibot/internal/notify"
 	"github.com/robherley/sendibot/pkg/sendico"
 )
 
+// DrainTimeout is how long in-flight jobs are given to finish when shutting down.
+const DrainTimeout = 30 * time.Second
+
 type Config struct {
-	DiscordToken string `desc:"API Token for Discord" required:"true"`
-	DatabaseFile string `desc:"Path of SQLite database file" default:"sendibot.db" required:"false"`
+	DiscordToken  string `desc:"API Token for Discord" required:"true"`
+	DatabaseFile  string `desc:"Path of SQLite database file" default:"sendibot.db" required:"false"`
+	RequestBudget int    `desc:"Maximum Sendico searches per hour across all subscriptions (0 is unlimited)" default:"600" required:"false"`
+	HourlyItemCap int    `desc:"Maximum items sent to a user per hour before the rest go into a digest (0 is unlimited)" default:"50" required:"false"`
+	WebhookSecret string `desc:"Secret to sign requests to the default webhook with, webhooks are disabled if unset" required:"false"`
+	WebhookURL    string `desc:"Default URL for subscriptions to post new items to" required:"false"`
+	SMTPHost      string `desc:"SMTP server to send emails through, email is disabled if unset" required:"false"`
+	SMTPPort      int    `desc:"Port of the SMTP server" default:"587" required:"false"`
+	SMTPUsername  string `desc:"Username to authenticate with the SMTP server, no auth is used if unset" required:"false"`
+	SMTPPassword  string `desc:"Password to authenticate with the SMTP server" required:"false"`
+	SMTPFrom      string `desc:"Address emails are sent from" default:"sendibot <sendibot@localhost>" required:"false"`
+	FeedAddr      string `desc:"Address to serve subscription feeds on, e.g. :8080, feeds are disabled if unset" required:"false"`
+	FeedURL       string `desc:"Public URL the feed server is reachable at" default:"http://localhost:8080" required:"false"`
+	HashImages    bool   `desc:"Download item images to find duplicate listings by how they look, not just by their titles" default:"true" required:"false"`
 }
 
 func init() {
@@ -75,7 +95,34 @@ func run() error {
 		return err
 	}
 
-	bot, err := bot.New(cfg.DiscordToken, db, sendico)
+	var email *notify.Email
+	if cfg.SMTPHost != "" {
+		email = notify.NewEmail(db, notify.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		})
+	}
+
+	push := notify.NewPush(db)
+
+	botOpts := []bot.Option{bot.WithPushes(push)}
+	if cfg.WebhookSecret != "" {
+		botOpts = append(botOpts, bot.WithWebhooks(cfg.WebhookURL != ""))
+	}
+	if email != nil {
+		botOpts = append(botOpts, bot.WithEmails(email))
+	}
+
+	var feeds *feed.Server
+	if cfg.FeedAddr != "" {
+		feeds = feed.New(db, cfg.FeedURL)
+		botOpts = append(botOpts, bot.WithFeeds(feeds.URL))
+	}
+
+	bot, err := bot.New(cfg.DiscordToken, db, sendico, botOpts...)
 	if err != nil {
 		return err
 	}
@@ -107,13 +154,52 @@ func run() error {
 
 	slog.Info("sendibot is initialized")
 
-	l := looper.New(db, sendico, bot)
-	go l.Notify(ctx)
-	go l.Cleanup(ctx)
-	go l.Refresh(ctx)
+	looperOpts := []looper.Option{
+		looper.WithNotifier(bot),
+		looper.WithNotifier(bot.Channels()),
+		looper.WithNotifier(bot.DiscordWebhooks()),
+		looper.WithNotifier(push),
+		looper.WithRequestBudget(cfg.RequestBudget),
+		looper.WithHourlyItemCap(cfg.HourlyItemCap),
+	}
+	if cfg.WebhookSecret != "" {
+		looperOpts = append(looperOpts, looper.WithNotifier(notify.NewWebhook(cfg.WebhookURL, cfg.WebhookSecret)))
+	}
+	if email != nil {
+		looperOpts = append(looperOpts, looper.WithNotifier(email))
+	}
+	if cfg.HashImages {
+		looperOpts = append(looperOpts, looper.WithHasher(dedup.NewHasher()))
+	}
+
+	l := looper.New(db, sendico, looperOpts...)
+	l.Start(ctx)
+
+	var feedServer *http.Server
+	if feeds != nil {
+		feedServer = &http.Server{Addr: cfg.FeedAddr, Handler: feeds, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			slog.Info("serving feeds", "addr", cfg.FeedAddr)
+			if err := feedServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("feed server failed", "err", err)
+			}
+		}()
+	}
 
 	wait()
-	return nil
+	cancel()
+
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), DrainTimeout)
+	defer drainCancel()
+
+	if feedServer != nil {
+		if err := feedServer.Shutdown(drainCtx); err != nil {
+			slog.Error("failed to shut down feed server", "err", err)
+		}
+	}
+
+	slog.Info("draining jobs", "timeout", DrainTimeout)
+	return l.Wait(drainCtx)
 }
 
 func wait() {
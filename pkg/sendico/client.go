@@ -216,10 +216,23 @@ func (c *Client) Translate(ctx context.Context, text string) (string, error) {
 	return response.Data, nil
 }
 
+// Sort is the order search results are returned in.
+type Sort string
+
+const (
+	// SortDefault is the shop's own relevance order.
+	SortDefault Sort = ""
+	// SortNewest returns the most recently listed items first.
+	SortNewest Sort = "new"
+)
+
 type SearchOptions struct {
 	TermJP   string
 	MinPrice *int
 	MaxPrice *int
+	Sort     Sort
+	// Seller limits the results to one seller's items, on shops that can watch sellers. TermJP can be empty then.
+	Seller string
 }
 
 // Search performs a search for the given term on the specified merchant. It will only return the first page of results.
@@ -239,6 +252,12 @@ func (c *Client) Search(ctx context.Context, shop Shop, opts SearchOptions) ([]I
 	}
 	params.Set("page", "1")
 	params.Set("search", opts.TermJP)
+	if opts.Seller != "" {
+		params.Set("seller_id", opts.Seller)
+	}
+	if opts.Sort != SortDefault {
+		params.Set("sort", string(opts.Sort))
+	}
 
 	q := path.Query()
 	for pair := params.Oldest(); pair != nil; pair = pair.Next() {
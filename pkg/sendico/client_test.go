@@ -2,7 +2,12 @@ package sendico_test
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/robherley/sendibot/pkg/sendico"
 	"github.com/stretchr/testify/assert"
@@ -32,8 +37,119 @@ func TestClientIntegration(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("Search newest first", func(t *testing.T) {
+		opts := sendico.SearchOptions{TermJP: "ゲームボーイsp"}
+		relevant, err := client.Search(ctx, sendico.Mercari, opts)
+		assert.NoError(t, err)
+
+		opts.Sort = sendico.SortNewest
+		newest, err := client.Search(ctx, sendico.Mercari, opts)
+		assert.NoError(t, err)
+
+		// upload times are only a heuristic for listing times, but sorting by newest should pull them forward
+		assert.False(t, medianUpload(newest).Before(medianUpload(relevant)))
+	})
+
+	t.Run("Search a seller's items", func(t *testing.T) {
+		opts := sendico.SearchOptions{TermJP: "ゲームボーイ"}
+		items, err := client.Search(ctx, sendico.Rakuten, opts)
+		assert.NoError(t, err)
+
+		var seller string
+		for _, item := range items {
+			if id, ok := item.SellerID(); ok {
+				seller = id
+				break
+			}
+		}
+		if seller == "" {
+			t.Skip("no results say who sells them")
+		}
+
+		opts.Seller = seller
+		items, err = client.Search(ctx, sendico.Rakuten, opts)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, items)
+
+		// seller watches rely on the search only returning the seller's items
+		for _, item := range items {
+			id, ok := item.SellerID()
+			assert.True(t, ok, item.Code)
+			assert.Equal(t, seller, id, item.Code)
+		}
+	})
+
 	t.Run("Translate", func(t *testing.T) {
 		_, err := client.Translate(ctx, "gameboy sp")
 		assert.NoError(t, err)
 	})
 }
+
+func medianUpload(items []sendico.Item) time.Time {
+	times := make([]time.Time, 0, len(items))
+	for _, item := range items {
+		if at, ok := item.ImageUploadedAt(); ok {
+			times = append(times, at)
+		}
+	}
+	if len(times) == 0 {
+		return time.Time{}
+	}
+	slices.SortFunc(times, time.Time.Compare)
+	return times[len(times)/2]
+}
+
+const nuxtPage = `<html><script id="__NUXT_DATA__" type="application/json">[{"$sapi_tokens":1},[2],"secret"]</script></html>`
+
+func TestClientSearchParams(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, nuxtPage)
+			return
+		}
+		query = r.URL.RawQuery
+		fmt.Fprint(w, `{"code":200,"data":{"items":[],"total_items":0}}`)
+	}))
+	defer server.Close()
+
+	client, err := sendico.New(context.Background(), sendico.WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc := []struct {
+		name   string
+		sort   sendico.Sort
+		seller string
+		want   string
+	}{
+		{
+			name: "default",
+			want: "global=1&page=1&search=%E3%83%9D%E3%82%B1%E3%83%A2%E3%83%B3",
+		},
+		{
+			name: "newest",
+			sort: sendico.SortNewest,
+			want: "global=1&page=1&search=%E3%83%9D%E3%82%B1%E3%83%A2%E3%83%B3&sort=new",
+		},
+		{
+			name:   "seller",
+			sort:   sendico.SortNewest,
+			seller: "123456789",
+			want:   "global=1&page=1&search=%E3%83%9D%E3%82%B1%E3%83%A2%E3%83%B3&seller_id=123456789&sort=new",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.Search(context.Background(), sendico.Mercari, sendico.SearchOptions{
+				TermJP: "ポケモン",
+				Sort:   tt.sort,
+				Seller: tt.seller,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, query)
+		})
+	}
+}
@@ -40,28 +40,37 @@ func BuildHMAC(in HMACInput) (*HMACAttributes, error) {
 		in.Nonce = uuid.New().String()
 	}
 
-	payload := orderedmap.New[string, any]()
-	payload.Set("url", in.Path)
-	payload.Set("body", in.Payload)
-	payload.Set("nonce", in.Nonce)
-	payload.Set("timestamp", in.Timestamp)
-
-	bytes, err := json.Marshal(payload)
+	signature, err := Sign(in.Secret, in.Path, in.Payload, in.Nonce, in.Timestamp)
 	if err != nil {
 		return nil, err
 	}
 
-	hash := hmac.New(sha256.New, []byte(in.Secret))
-	hash.Write(bytes)
-	signature := hash.Sum(nil)
-
 	return &HMACAttributes{
-		Signature: fmt.Sprintf("%x", signature),
+		Signature: signature,
 		Nonce:     in.Nonce,
 		Timestamp: in.Timestamp,
 	}, nil
 }
 
+// Sign returns the hex HMAC-SHA256 signature of a request, keyed with secret. It is over the JSON of the request path,
+// body, nonce and timestamp in that order.
+func Sign(secret, path string, body any, nonce string, timestamp int64) (string, error) {
+	payload := orderedmap.New[string, any]()
+	payload.Set("url", path)
+	payload.Set("body", body)
+	payload.Set("nonce", nonce)
+	payload.Set("timestamp", timestamp)
+
+	bytes, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	hash := hmac.New(sha256.New, []byte(secret))
+	hash.Write(bytes)
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
 func DecodeHMACKey(key string) string {
 	decoded := make([]rune, len(key))
 	for i, char := range key {
@@ -3,6 +3,8 @@ package sendico
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"strconv"
 	"time"
 )
 
@@ -41,3 +43,24 @@ func (i *Item) SendicoLink() string {
 func (i *Item) IsAuction() bool {
 	return i.Auction != nil
 }
+
+// ImageUploadedAt returns when the item's photos were uploaded, if the shop exposes it. Listings don't carry a
+// creation time, so this is only a heuristic for when the item was listed: Mercari and Rakuma version their image URLs
+// with the unix time of the upload, which moves forward whenever the seller edits the photos of an older listing.
+func (i *Item) ImageUploadedAt() (time.Time, bool) {
+	if i.Shop != Mercari && i.Shop != Rakuma {
+		return time.Time{}, false
+	}
+
+	u, err := url.Parse(i.Image)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	secs, err := strconv.ParseInt(u.RawQuery, 10, 64)
+	if err != nil || secs <= 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(secs, 0).UTC(), true
+}
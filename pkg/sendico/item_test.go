@@ -204,3 +204,47 @@ func TestItemSendicoLink(t *testing.T) {
 
 	assert.Equal(t, "https://sendico.com/shop/ayahoo/catalog/e1160102473", i.SendicoLink())
 }
+
+func TestItemImageUploadedAt(t *testing.T) {
+	tc := []struct {
+		name   string
+		data   string
+		want   time.Time
+		wantOK bool
+	}{
+		{
+			name:   "mercari",
+			data:   mercari,
+			want:   time.Unix(1730955439, 0).UTC(),
+			wantOK: true,
+		},
+		{
+			name:   "rakuma",
+			data:   rakuma,
+			want:   time.Unix(1729993881, 0).UTC(),
+			wantOK: true,
+		},
+		{
+			name: "ayahoo",
+			data: ayahoo,
+		},
+		{
+			name: "rakuten",
+			data: rakuten,
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			var i sendico.Item
+			err := json.Unmarshal([]byte(tt.data), &i)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, ok := i.ImageUploadedAt()
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package sendico
+
+import (
+	"net/url"
+	"strings"
+)
+
+// HasSellers returns whether the shop's items say who sells them. Search results don't include sellers, but Rakuten and
+// Yahoo Shopping items are sold by stores whose IDs are part of their codes and URLs.
+func (s Shop) HasSellers() bool {
+	return s == Rakuten || s == Yahoo
+}
+
+// SellerID returns the ID of the store selling the item, if the shop exposes it.
+func (i *Item) SellerID() (string, bool) {
+	switch i.Shop {
+	case Rakuten:
+		// codes are "<store>:<item>"
+		if store, _, ok := strings.Cut(i.Code, ":"); ok && store != "" {
+			return store, true
+		}
+	case Yahoo:
+		if u, err := url.Parse(i.URL); err == nil && u.Host == "store.shopping.yahoo.co.jp" {
+			if store, _, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/"); store != "" {
+				return store, true
+			}
+		}
+		// codes are "<store>_<item>", store IDs can have underscores of their own
+		if sep := strings.LastIndex(i.Code, "_"); sep > 0 {
+			return i.Code[:sep], true
+		}
+	}
+
+	return "", false
+}
+
+// ParseSeller returns the shop and seller ID of a link to a seller's store, profile or to one of their items, on the
+// shop or on Sendico. Sendico links to items of shops that don't expose sellers return the shop, but aren't ok.
+func ParseSeller(link string) (Shop, string, bool) {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return 0, "", false
+	}
+
+	segments := strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' })
+	if len(segments) == 0 {
+		return 0, "", false
+	}
+
+	switch u.Host {
+	case "item.rakuten.co.jp", "www.rakuten.co.jp":
+		return Rakuten, segments[0], true
+	case "store.shopping.yahoo.co.jp":
+		return Yahoo, segments[0], true
+	case "jp.mercari.com":
+		// e.g. /user/profile/<id>
+		if len(segments) == 3 && segments[0] == "user" && segments[1] == "profile" {
+			return Mercari, segments[2], true
+		}
+		return 0, "", false
+	}
+
+	if base, err := url.Parse(DefaultBaseURL); err == nil && u.Host == base.Host {
+		// e.g. /shop/rakuten/catalog/<code>
+		if len(segments) != 4 || segments[0] != "shop" || segments[2] != "catalog" {
+			return 0, "", false
+		}
+
+		shop, ok := ShopMap[segments[1]]
+		if !ok {
+			return 0, "", false
+		}
+
+		item := Item{Shop: shop, Code: segments[3]}
+		if seller, ok := item.SellerID(); ok {
+			return shop, seller, true
+		}
+		return shop, "", false
+	}
+
+	return 0, "", false
+}
@@ -0,0 +1,139 @@
+package sendico_test
+
+import (
+	"testing"
+
+	"github.com/robherley/sendibot/pkg/sendico"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestItemSellerID(t *testing.T) {
+	tc := []struct {
+		name   string
+		item   sendico.Item
+		want   string
+		wantOk bool
+	}{
+		{
+			name:   "rakuten",
+			item:   sendico.Item{Shop: sendico.Rakuten, Code: "centerwave:10000751"},
+			want:   "centerwave",
+			wantOk: true,
+		},
+		{
+			name:   "yahoo from url",
+			item:   sendico.Item{Shop: sendico.Yahoo, Code: "coko_tokyo_10433", URL: "https://store.shopping.yahoo.co.jp/coko_tokyo/10433.html"},
+			want:   "coko_tokyo",
+			wantOk: true,
+		},
+		{
+			name:   "yahoo from code",
+			item:   sendico.Item{Shop: sendico.Yahoo, Code: "cokotokyo_10433"},
+			want:   "cokotokyo",
+			wantOk: true,
+		},
+		{
+			name:   "yahoo from code with an underscore in the store",
+			item:   sendico.Item{Shop: sendico.Yahoo, Code: "coko_tokyo_10433"},
+			want:   "coko_tokyo",
+			wantOk: true,
+		},
+		{
+			name: "yahoo malformed code",
+			item: sendico.Item{Shop: sendico.Yahoo, Code: "_10433"},
+		},
+		{
+			name: "malformed code",
+			item: sendico.Item{Shop: sendico.Rakuten, Code: "10000751"},
+		},
+		{
+			name: "mercari",
+			item: sendico.Item{Shop: sendico.Mercari, Code: "m69480508468", URL: "https://jp.mercari.com/item/m69480508468"},
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.item.SellerID()
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseSeller(t *testing.T) {
+	tc := []struct {
+		link     string
+		wantShop sendico.Shop
+		want     string
+		wantOk   bool
+	}{
+		{
+			link:     "https://item.rakuten.co.jp/centerwave/10000751/",
+			wantShop: sendico.Rakuten,
+			want:     "centerwave",
+			wantOk:   true,
+		},
+		{
+			link:     "https://www.rakuten.co.jp/centerwave/",
+			wantShop: sendico.Rakuten,
+			want:     "centerwave",
+			wantOk:   true,
+		},
+		{
+			link:     "https://store.shopping.yahoo.co.jp/cokotokyo/10433.html",
+			wantShop: sendico.Yahoo,
+			want:     "cokotokyo",
+			wantOk:   true,
+		},
+		{
+			link:     " https://sendico.com/shop/rakuten/catalog/centerwave:10000751 ",
+			wantShop: sendico.Rakuten,
+			want:     "centerwave",
+			wantOk:   true,
+		},
+		{
+			link:     "https://sendico.com/shop/mercari/catalog/m69480508468",
+			wantShop: sendico.Mercari,
+		},
+		{
+			link: "https://sendico.com/shop/garbage/catalog/m1",
+		},
+		{
+			link:     "https://store.shopping.yahoo.co.jp/coko_tokyo/10433.html",
+			wantShop: sendico.Yahoo,
+			want:     "coko_tokyo",
+			wantOk:   true,
+		},
+		{
+			link:     "https://sendico.com/shop/yahoo/catalog/coko_tokyo_10433",
+			wantShop: sendico.Yahoo,
+			want:     "coko_tokyo",
+			wantOk:   true,
+		},
+		{
+			link:     "https://jp.mercari.com/user/profile/123456789",
+			wantShop: sendico.Mercari,
+			want:     "123456789",
+			wantOk:   true,
+		},
+		{
+			link: "https://jp.mercari.com/item/m69480508468",
+		},
+		{
+			link: "https://www.rakuten.co.jp/",
+		},
+		{
+			link: "not a link",
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.link, func(t *testing.T) {
+			shop, seller, ok := sendico.ParseSeller(tt.link)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantShop, shop)
+			assert.Equal(t, tt.want, seller)
+		})
+	}
+}